@@ -0,0 +1,379 @@
+// archive.go
+// list and extract members of tar and zip archives
+//
+// Copyright (c) 2024-2026 jjb
+// All rights reserved.
+//
+// This source code is licensed under the MIT license found
+// in the root directory of this source tree.
+
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Archive formats.
+const (
+	ARCHIVE_NONE = iota
+	ARCHIVE_TAR
+	ARCHIVE_ZIP
+)
+
+// TAR_MAGIC_OFFSET is where a tar header holds the ustar magic.
+const TAR_MAGIC_OFFSET = 257
+
+// MEMBER_SEP joins an archive and a member name into a member path,
+// such as bundle.tar.gz::logs/app.log.
+const MEMBER_SEP = "::"
+
+// archiveMember is one file listed in an archive.
+type archiveMember struct {
+	name string
+	info fs.FileInfo
+	link string
+}
+
+// zipMagic lists the leading bytes of zip archives, empty or not.
+var zipMagic = [][]byte{
+	[]byte("PK\x03\x04"),
+	[]byte("PK\x05\x06"),
+}
+
+// tarMagic starts the magic of POSIX and GNU tar headers.
+var tarMagic = []byte("ustar")
+
+// archiveProbe is the format found for a file of a size and mtime.
+type archiveProbe struct {
+	size  int64
+	mtime time.Time
+	kind  int
+}
+
+// archiveTypes caches the format of each file probed, by path.
+var archiveTypes sync.Map
+
+// archiveType identifies zip archives and tar archives, which may be
+// compressed in any format openDecompressed knows. A file is probed once
+// until it changes.
+func archiveType(fileName string) int {
+	info, err := os.Stat(fileName)
+	if err != nil || !info.Mode().IsRegular() {
+		return ARCHIVE_NONE
+	}
+
+	if v, ok := archiveTypes.Load(fileName); ok {
+		if probe := v.(archiveProbe); probe.size == info.Size() && probe.mtime.Equal(info.ModTime()) {
+			return probe.kind
+		}
+	}
+
+	kind := probeArchive(fileName)
+	archiveTypes.Store(fileName, archiveProbe{size: info.Size(), mtime: info.ModTime(), kind: kind})
+	return kind
+}
+
+// probeArchive looks for zip magic at the start of a file, and for tar
+// magic in its first header once it is decompressed.
+func probeArchive(fileName string) int {
+	fp, err := os.Open(fileName)
+	if err != nil {
+		return ARCHIVE_NONE
+	}
+	defer fp.Close()
+
+	head := make([]byte, 4)
+	if n, _ := fp.ReadAt(head, 0); n == len(head) {
+		for _, magic := range zipMagic {
+			if bytes.Equal(head, magic) {
+				return ARCHIVE_ZIP
+			}
+		}
+	}
+
+	rc, err := openDecompressed(fp)
+	if err != nil {
+		rc.Close()
+		return ARCHIVE_NONE
+	}
+	defer rc.Close()
+
+	block := make([]byte, TAR_MAGIC_OFFSET+len(tarMagic))
+	if _, err := io.ReadFull(rc, block); err != nil || !bytes.Equal(block[TAR_MAGIC_OFFSET:], tarMagic) {
+		return ARCHIVE_NONE
+	}
+
+	return ARCHIVE_TAR
+}
+
+// openTar opens a tar archive through any compression.
+func openTar(fileName string) (*tar.Reader, io.Closer, error) {
+	fp, err := os.Open(fileName)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rc, err := openDecompressed(fp)
+	if err != nil {
+		rc.Close()
+		fp.Close()
+		return nil, nil, err
+	}
+
+	return tar.NewReader(rc), &multiCloser{closers: []io.Closer{rc, fp}}, nil
+}
+
+// listArchive returns the files in an archive of a kind archiveType
+// found, without directories.
+func listArchive(fileName string, kind int) ([]archiveMember, error) {
+	var members []archiveMember
+
+	if kind == ARCHIVE_ZIP {
+		zr, err := zip.OpenReader(fileName)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+
+		for _, f := range zr.File {
+			if info := f.FileInfo(); !info.IsDir() {
+				members = append(members, archiveMember{name: f.Name, info: info})
+			}
+		}
+
+		return members, nil
+	}
+
+	tr, closer, err := openTar(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer closer.Close()
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return members, nil
+		}
+
+		if err != nil {
+			// keep what was listed before the damage
+			if len(members) > 0 {
+				return members, nil
+			}
+			return nil, err
+		}
+
+		switch hdr.Typeflag {
+
+		case tar.TypeReg, tar.TypeSymlink, tar.TypeLink:
+			members = append(members, archiveMember{
+				name: hdr.Name,
+				info: hdr.FileInfo(),
+				link: hdr.Linkname,
+			})
+		}
+	}
+}
+
+// listArchiveSpool spools a listing of an archive's members.
+func listArchiveSpool(fileName string, kind int) (*spoolObj, *dirListing, error) {
+	members, err := listArchive(fileName, kind)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	listing := &dirListing{path: fileName, archive: true}
+
+	var buf bytes.Buffer
+	for _, m := range members {
+		listing.entries = append(listing.entries, m.name)
+		buf.WriteString(formatDirEntry(m.name, m.info, m.link))
+		buf.WriteByte('\n')
+	}
+
+	sp, err := newSpool(io.NopCloser(&buf), fileName, buf.Len())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return sp, listing, nil
+}
+
+// splitMemberPath splits a member path into its archive and member. A
+// real file named like a member path is not split.
+func splitMemberPath(memberPath string) (string, string, bool) {
+	archive, member, found := strings.Cut(memberPath, MEMBER_SEP)
+	if !found || member == "" {
+		return "", "", false
+	}
+
+	if _, err := os.Lstat(memberPath); err == nil {
+		return "", "", false
+	}
+
+	if info, err := os.Stat(archive); err != nil || !info.Mode().IsRegular() {
+		return "", "", false
+	}
+
+	return archive, member, true
+}
+
+// openMemberReader opens the contents of a member of an archive of a
+// kind archiveType found. Links are followed to the member they name.
+func openMemberReader(archive, member string, kind int) (io.ReadCloser, error) {
+	const maxLinks = 8
+
+	if kind == ARCHIVE_ZIP {
+		zr, err := zip.OpenReader(archive)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, f := range zr.File {
+			if f.Name == member {
+				rc, err := f.Open()
+				if err != nil {
+					zr.Close()
+					return nil, err
+				}
+
+				return &multiCloser{Reader: rc, closers: []io.Closer{rc, zr}}, nil
+			}
+		}
+
+		zr.Close()
+		return nil, fmt.Errorf("%s: no such member", member)
+	}
+
+	for range maxLinks {
+		tr, closer, err := openTar(archive)
+		if err != nil {
+			return nil, err
+		}
+
+		var hdr *tar.Header
+		for {
+			if hdr, err = tr.Next(); err != nil || path.Clean(hdr.Name) == path.Clean(member) {
+				break
+			}
+		}
+
+		if err != nil {
+			closer.Close()
+			if err == io.EOF {
+				return nil, fmt.Errorf("%s: no such member", member)
+			}
+			return nil, err
+		}
+
+		switch hdr.Typeflag {
+
+		case tar.TypeSymlink:
+			closer.Close()
+			member = path.Join(path.Dir(member), hdr.Linkname)
+
+		case tar.TypeLink:
+			closer.Close()
+			member = hdr.Linkname
+
+		default:
+			return &multiCloser{Reader: tr, closers: []io.Closer{closer}}, nil
+		}
+	}
+
+	return nil, errors.New("too many levels of links")
+}
+
+// spoolMember returns the spool holding an archive member, extracting it
+// on first use. Compressed members are decompressed.
+func spoolMember(memberPath string) (*spoolObj, error) {
+	const primeSize = 4 * 1024
+
+	if sp, ok := sourceSpool(memberPath); ok {
+		return sp, nil
+	}
+
+	archive, member, ok := splitMemberPath(memberPath)
+	if !ok {
+		return nil, fmt.Errorf("%s: not an archive member", memberPath)
+	}
+
+	rc, err := openMemberReader(archive, member, archiveType(archive))
+	if err != nil {
+		return nil, err
+	}
+
+	// raw contents are copied if they cannot be decompressed
+	warning := new(atomic.Pointer[string])
+	src, err := openDecompressed(rc)
+	if err != nil {
+		decompressWarning(warning, err)
+	}
+
+	sp, err := newSpool(&multiCloser{Reader: src, closers: []io.Closer{src, rc}},
+		memberPath, primeSize)
+	if err != nil {
+		return nil, err
+	}
+	sp.warning = warning
+
+	sourceSpools.Store(memberPath, sp)
+	return sp, nil
+}
+
+// expandMembers expands an archive::glob token, or a bare glob typed in an
+// archive listing, into member paths. It returns false for other tokens.
+func (br *browseObj) expandMembers(tok string) ([]string, bool) {
+	archive, pattern, ok := splitMemberPath(tok)
+	if !ok {
+		if br.dirList == nil || !br.dirList.archive || !strings.ContainsAny(tok, "*?[") {
+			return nil, false
+		}
+
+		archive, pattern = br.dirList.path, tok
+	}
+
+	if !strings.ContainsAny(pattern, "*?[") {
+		return []string{tok}, true
+	}
+
+	var names []string
+	if br.dirList != nil && br.dirList.archive && br.dirList.path == archive {
+		names = br.dirList.entries
+	} else {
+		members, _ := listArchive(archive, archiveType(archive))
+		for _, m := range members {
+			names = append(names, m.name)
+		}
+	}
+
+	var matches []string
+	for _, name := range names {
+		// patterns without a slash match anywhere in the archive
+		target := path.Clean(name)
+		if !strings.Contains(pattern, "/") {
+			target = path.Base(name)
+		}
+
+		if matched, _ := path.Match(pattern, target); matched {
+			matches = append(matches, archive+MEMBER_SEP+name)
+		}
+	}
+
+	return matches, true
+}
+
+// vim: set ts=4 sw=4 noet:
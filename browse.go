@@ -13,10 +13,50 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
+	"time"
 )
 
+// stdinStream is piped input being copied to a temporary file. Both panes
+// of a split screen may browse it, so its end is shared.
+type stdinStream struct {
+	eof   atomic.Bool
+	mutex sync.Mutex
+	panes []*browseObj
+}
+
+// join adds a pane browsing the input.
+func (s *stdinStream) join(br *browseObj) {
+	s.mutex.Lock()
+	s.panes = append(s.panes, br)
+	s.mutex.Unlock()
+
+	br.stdin = s
+}
+
+// finish marks the input complete and wakes the reader of every pane, so
+// they publish the last line.
+func (s *stdinStream) finish() {
+	s.eof.Store(true)
+
+	s.mutex.Lock()
+	panes := slices.Clone(s.panes)
+	s.mutex.Unlock()
+
+	for _, br := range panes {
+		br.wakeReader()
+	}
+}
+
+// stdinComplete reports whether piped input has been copied in full.
+func (br *browseObj) stdinComplete() bool {
+	return br.stdin != nil && br.stdin.eof.Load()
+}
+
 // processPipeInput handles input piped on stdin into a temporary file for browsing.
 func processPipeInput(br *browseObj) {
 	fpStdin, err := os.CreateTemp("", "browse")
@@ -26,17 +66,21 @@ func processPipeInput(br *browseObj) {
 	}
 	defer os.Remove(fpStdin.Name())
 
-	br.mutex.Lock()
-	br.stdinEOF = false
-	br.mutex.Unlock()
+	stdin := &stdinStream{}
+	stdin.join(br)
 
 	// Goroutine owns fpStdin and closes it when stdin is exhausted
+	warning := new(atomic.Pointer[string])
 	go func() {
-		br.readStdin(os.Stdin, fpStdin)
-		br.mutex.Lock()
-		br.stdinEOF = true
-		br.mutex.Unlock()
+		// raw input is copied if it cannot be decompressed
+		src, err := openDecompressed(os.Stdin)
+		if err != nil {
+			decompressWarning(warning, err)
+		}
+		br.readStdin(src, fpStdin)
+		src.Close()
 		fpStdin.Close()
+		stdin.finish()
 	}()
 
 	// Fast path for stdin: open temp file ourselves and pass to browseFile
@@ -47,14 +91,55 @@ func processPipeInput(br *browseObj) {
 	}
 	defer fp.Close()
 
+	// detection needs the head of the input
+	waitForHead(fp, stdin.eof.Load)
+
+	if msg := warning.Load(); msg != nil {
+		br.timedMessage(*msg, MSG_ORANGE)
+	}
+
 	// Save arg list
 	br.currentList = []string{fpStdin.Name()}
+	br.listLen = 1
 	br.listAtStart = true
-	browseFile(br, fp, fpStdin.Name(), "          ", true)
+	br.listDepth++
+
+	for {
+		browseFile(br, fp, fpStdin.Name(), "          ", true)
+
+		// return to the input from a nested list
+		if br.listAction != LIST_ACTION_RESUME {
+			return
+		}
+
+		br.listAction = LIST_ACTION_NONE
+		restoreResumeState(br)
+	}
+}
+
+// waitForHead gives streamed input a moment to fill the sample used to
+// detect binary and encoded input.
+func waitForHead(fp *os.File, complete func() bool) {
+	const (
+		sampleSize   = 4 * 1024
+		maxAttempts  = 20
+		waitInterval = 25 * time.Millisecond
+	)
+
+	for range maxAttempts {
+		if info, err := fp.Stat(); complete() || (err == nil && info.Size() >= sampleSize) {
+			return
+		}
+
+		time.Sleep(waitInterval)
+	}
 }
 
 // processFileList iterates through a list of files and opens them for browsing.
 func processFileList(br *browseObj, args []string, toplevel bool) bool {
+	br.listDepth++
+	defer func() { br.listDepth-- }()
+
 	if len(args) == 0 {
 		abs, err := filepath.Abs(br.fileName)
 		if err != nil {
@@ -68,6 +153,7 @@ func processFileList(br *browseObj, args []string, toplevel bool) bool {
 			}
 
 			br.currentList = []string{abs}
+			br.listLen = 1
 			br.listAtStart = true
 			br.absFileName = abs
 			browseFile(br, fp, br.absFileName, setTitle(br.title, abs), false)
@@ -92,10 +178,13 @@ func processFileList(br *browseObj, args []string, toplevel bool) bool {
 	}
 
 	savedList := br.currentList
+	savedListLen := br.listLen
 	savedListAtStart := br.listAtStart
 	defer func() {
 		br.currentList = savedList
+		br.listLen = savedListLen
 		br.listAtStart = savedListAtStart
+		br.handoff = nil
 	}()
 
 	// Build absolute and symlink-resolved paths against the starting cwd
@@ -112,14 +201,39 @@ func processFileList(br *browseObj, args []string, toplevel bool) bool {
 	}
 
 	br.currentList = args
+	br.listLen = len(args)
 	br.listAtStart = true
 	lastIdx := len(args) - 1
+	lastOpened := -1
 	openedAny := false
 
 	for i := 0; i < len(args); i++ {
 		fileName := args[i]
 		fp, err := validateAndOpenFile(br, absArgs[i])
 		if err != nil {
+			// a reverse search skips back over unreadable files
+			if br.handoff != nil && !br.handoff.forward && i > 0 {
+				i -= 2
+				continue
+			}
+
+			// a search that runs out of files ends in the last file it
+			// searched
+			end := lastIdx
+			if br.handoff != nil && !br.handoff.forward {
+				end = 0
+			}
+
+			if br.handoff != nil && i == end {
+				if lastOpened >= 0 {
+					br.resume = br.handoff.left
+					restoreResumeState(br)
+					br.handoff.notFound = true
+					i = lastOpened - 1
+				} else if !br.handoff.forward {
+					br.handoff = nil
+				}
+			}
 			continue
 		}
 
@@ -131,6 +245,7 @@ func processFileList(br *browseObj, args []string, toplevel bool) bool {
 		br.absFileName = absArgs[i]
 		br.currentList = args[i:]
 		br.listAtStart = i == 0
+		lastOpened = i
 		openedAny = true
 		browseFile(br, fp, absArgs[i], fileName, false)
 		fp.Close()
@@ -149,6 +264,13 @@ func processFileList(br *browseObj, args []string, toplevel bool) bool {
 			continue
 		}
 
+		if br.listAction == LIST_ACTION_PREV {
+			br.listAction = LIST_ACTION_NONE
+			resetState(br)
+			i -= 2
+			continue
+		}
+
 		if br.listAction == LIST_ACTION_EXIT_ALL {
 			break
 		}
@@ -172,11 +294,86 @@ func processFileList(br *browseObj, args []string, toplevel bool) bool {
 // browseFile initializes browsing state for a file and begins processing.
 func browseFile(br *browseObj, fp *os.File, fileName, title string, fromStdin bool) {
 	targetFile := strings.TrimSuffix(fileName, "/")
+	browseFp, browseName := fp, targetFile
 
-	checkBinaryFile(br, fp, targetFile)
-	br.fileInit(fp, targetFile, title, fromStdin)
+	// Directories and archives are browsed as a listing, compressed files
+	// through a decompressed spool
+	var sp *spoolObj
+	br.dirList = nil
+
+	archive := ARCHIVE_NONE
+	if !fromStdin {
+		archive = archiveType(targetFile)
+	}
 
-	if !br.fromStdin {
+	if info, err := fp.Stat(); err == nil && info.IsDir() {
+		if sp, br.dirList, err = listDirectory(fp, targetFile); err != nil {
+			br.userAnyKey(fmt.Sprintf("%s %s: cannot list ... [press any key] %s",
+				MSG_RED, lastNChars(targetFile, br.dispWidth), VIDOFF))
+			return
+		}
+		br.dirCursor = max(br.dirCursor, 1)
+	} else if src, ok := spoolLookup(targetFile); ok && src.listing != nil {
+		// generated listings, such as grep results, are browsed in place
+		br.dirList = src.listing
+		br.dirCursor = max(br.dirCursor, 1)
+	} else if src, ok := sourceSpool(targetFile); ok {
+		// the spool outlives this visit so the list can return to it
+		browseName = src.name
+		waitForHead(fp, src.eof.Load)
+		if msg := src.rawWarning(); msg != "" {
+			br.timedMessage(msg, MSG_ORANGE)
+		}
+	} else if archive != ARCHIVE_NONE {
+		var err error
+		if sp, br.dirList, err = listArchiveSpool(targetFile, archive); err != nil {
+			br.userAnyKey(fmt.Sprintf("%s %s: cannot list ... [press any key] %s",
+				MSG_RED, lastNChars(targetFile, br.dispWidth), VIDOFF))
+			return
+		}
+		br.dirCursor = max(br.dirCursor, 1)
+	} else if !fromStdin {
+		sp = spoolCompressed(br, fp, targetFile)
+	}
+
+	if sp != nil {
+		browseFp, browseName = sp.fp, sp.name
+		defer func() {
+			// retire the reader before its file goes away
+			br.mutex.Lock()
+			br.fileSeq++
+			br.mutex.Unlock()
+			sp.close()
+			br.dirList = nil
+		}()
+	}
+
+	// --encoding overrides detection
+	br.encoding = br.encodingOpt
+	if br.encoding == ENC_AUTO {
+		br.encoding = detectEncoding(browseFp)
+	}
+
+	checkBinaryFile(br, browseFp, targetFile)
+
+	// listings are never colored
+	br.syntax = nil
+	br.logView = false
+	if br.dirList == nil {
+		br.syntax = detectSyntax(browseFp, targetFile)
+		br.logView = fromStdin || isLogName(targetFile)
+	}
+
+	// CSV and TSV files open in columns; a view returned to keeps its own
+	if br.table == nil && br.dirList == nil && !br.modeHex && tableName(targetFile) != 0 {
+		br.table = detectTable(browseFp, targetFile)
+		br.modeTable = br.table != nil
+	}
+	br.setScreenSize(br.termWidth, br.termHeight)
+
+	br.fileInit(browseFp, browseName, title, fromStdin)
+
+	if !br.fromStdin && !isStreamFile(targetFile) && !isSpoolFile(targetFile) {
 		updateHistory(targetFile, fileHistory)
 	}
 
@@ -185,6 +382,22 @@ func browseFile(br *browseObj, fp *os.File, fileName, title string, fromStdin bo
 
 // validateAndOpenFile opens a file and validates it is suitable for browsing.
 func validateAndOpenFile(br *browseObj, targetFile string) (*os.File, error) {
+	// keys are read from the terminal, so it cannot be browsed
+	if isTerminalFile(targetFile) {
+		br.userAnyKey(fmt.Sprintf("%s %s: is a terminal ... [press any key] %s",
+			MSG_RED, lastNChars(targetFile, br.dispWidth), VIDOFF))
+		return nil, fmt.Errorf("%s: is a terminal", targetFile)
+	}
+
+	// pipes, devices and archive members are browsed through their spool
+	if isStreamFile(targetFile) {
+		return openSpooled(br, targetFile, spoolStream)
+	}
+
+	if _, _, ok := splitMemberPath(targetFile); ok {
+		return openSpooled(br, targetFile, spoolMember)
+	}
+
 	fp, err := os.Open(targetFile)
 	if err != nil {
 		br.userAnyKey(fmt.Sprintf("%s %s: cannot open ... [press any key] %s",
@@ -200,14 +413,8 @@ func validateAndOpenFile(br *browseObj, targetFile string) (*os.File, error) {
 		return nil, err
 	}
 
-	if stat.IsDir() {
-		fp.Close()
-		br.userAnyKey(fmt.Sprintf("%s %s: is a directory ... [press any key] %s",
-			MSG_RED, lastNChars(targetFile, br.dispWidth), VIDOFF))
-		return nil, fmt.Errorf("%s: is a directory", targetFile)
-	}
-
-	if !stat.Mode().IsRegular() {
+	// directories open as a listing
+	if !stat.IsDir() && !stat.Mode().IsRegular() {
 		fp.Close()
 		br.userAnyKey(fmt.Sprintf("%s %s: not a regular file ... [press any key] %s",
 			MSG_RED, lastNChars(targetFile, br.dispWidth), VIDOFF))
@@ -217,10 +424,29 @@ func validateAndOpenFile(br *browseObj, targetFile string) (*os.File, error) {
 	return fp, nil
 }
 
-// checkBinaryFile warns if the target file appears to be binary.
+// openSpooled starts or rejoins the spool of a stream or archive member
+// and opens it.
+func openSpooled(br *browseObj, targetFile string, start func(string) (*spoolObj, error)) (*os.File, error) {
+	sp, err := start(targetFile)
+	if err == nil {
+		var fp *os.File
+		if fp, err = os.Open(sp.name); err == nil {
+			return fp, nil
+		}
+	}
+
+	br.userAnyKey(fmt.Sprintf("%s %s: cannot open ... [press any key] %s",
+		MSG_RED, lastNChars(targetFile, br.dispWidth), VIDOFF))
+	return nil, err
+}
+
+// checkBinaryFile shows binary files in the hex view.
 func checkBinaryFile(br *browseObj, fp *os.File, targetFile string) {
-	if isBinaryFileFp(fp) {
-		br.timedMessage(fmt.Sprintf("%s: is a binary file", filepath.Base(targetFile)), MSG_ORANGE)
+	// NUL bytes are expected in UTF-16 text
+	br.modeHex = !isUTF16(br.encoding) && isBinaryFileFp(fp)
+
+	if br.modeHex {
+		br.timedMessage(fmt.Sprintf("%s: is a binary file, showing hex", filepath.Base(targetFile)), MSG_ORANGE)
 	}
 }
 
@@ -237,8 +463,10 @@ func processFileBrowsing(br *browseObj) {
 
 	commands(br)
 
-	// Save session state if requested
-	if !br.fromStdin && br.saveRC {
+	// Save session state if requested; streams and spools cannot be reopened,
+	// and the session is the first pane's
+	if !br.fromStdin && !isStreamFile(br.absFileName) && !isSpoolFile(br.absFileName) && br.saveRC &&
+		!br.isSecondPane() {
 		br.writeRcFile()
 	}
 }
@@ -247,10 +475,34 @@ func processFileBrowsing(br *browseObj) {
 func resetState(br *browseObj) {
 	br.firstRow = 0
 	br.lastRow = 0
+	br.firstSeg = 0
+	br.lastSeg = 0
 	br.shiftWidth = 0
+	br.dirCursor = 0
+	br.filter = nil
+	br.table = nil
+	br.modeTable = false
+	br.setSearchField(nil)
 	br.modeScroll = MODE_SCROLL_NONE
 }
 
+// saveResumeState records the visible position before a nested list opens.
+func (br *browseObj) saveResumeState() browseResumeState {
+	return browseResumeState{
+		fileName:    br.fileName,
+		absFileName: br.absFileName,
+		title:       br.title,
+		fromStdin:   br.fromStdin,
+		firstRow:    br.firstRow,
+		lastRow:     br.lastRow,
+		shiftWidth:  br.shiftWidth,
+		dirCursor:   br.dirCursor,
+		filter:      br.filter,
+		table:       br.table,
+		modeTable:   br.modeTable,
+	}
+}
+
 // restoreResumeState restores the parent file's viewport after a nested list.
 func restoreResumeState(br *browseObj) {
 	br.fileName = br.resume.fileName
@@ -259,7 +511,13 @@ func restoreResumeState(br *browseObj) {
 	br.fromStdin = br.resume.fromStdin
 	br.firstRow = br.resume.firstRow
 	br.lastRow = br.resume.lastRow
+	br.firstSeg = 0
+	br.lastSeg = 0
 	br.shiftWidth = br.resume.shiftWidth
+	br.dirCursor = br.resume.dirCursor
+	br.filter = br.resume.filter
+	br.table = br.resume.table
+	br.modeTable = br.resume.modeTable
 	br.modeScroll = MODE_SCROLL_NONE
 }
 
@@ -270,6 +528,15 @@ func preInitialization() {
 	syscall.Umask(077)
 }
 
+// sourceName returns the file being browsed, looking through any spool.
+func (br *browseObj) sourceName() string {
+	if sp, ok := spoolLookup(br.fileName); ok {
+		return sp.source
+	}
+
+	return br.fileName
+}
+
 // setTitle returns the primary title when set, otherwise the fallback.
 func setTitle(primary, fallback string) string {
 	if primary != "" {
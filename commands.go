@@ -18,7 +18,6 @@ import (
 	"strings"
 	"time"
 	"unicode"
-	"unicode/utf8"
 )
 
 // ─── Command Groups ─────────────────────────────────────────────────
@@ -52,8 +51,13 @@ const (
 	CMD_SEARCH_NEXT_REV = 'N'
 	CMD_SEARCH_IGN_CASE = 'i'
 	CMD_SEARCH_FIXED    = 'I'
+	CMD_SEARCH_SMART    = 's'
+	CMD_SEARCH_WORD     = 'W'
+	CMD_SEARCH_LIST     = 'A'
 	CMD_SEARCH_PRINT    = 'p'
 	CMD_SEARCH_CLEAR    = 'P'
+	CMD_SEARCH_PIN      = '*'
+	CMD_SEARCH_UNPIN    = 'U'
 
 	// Horizontal scrolling commands
 	CMD_SHIFT_LEFT    = '<'
@@ -77,16 +81,34 @@ const (
 	CMD_EXIT_ALL         = '\030'
 	CMD_EXIT_ALL_NO_SAVE = '\031'
 
+	// Split screen
+	CMD_SPLIT        = 'o'
+	CMD_SPLIT_VERT   = 'O'
+	CMD_SPLIT_FOCUS  = '\027'
+	CMD_SPLIT_GROW   = ']'
+	CMD_SPLIT_SHRINK = '['
+	CMD_SPLIT_LOCK   = 'K'
+
 	// Other commands
 	CMD_ARGLIST   = 'a'
 	CMD_BASH      = '!'
 	CMD_FORMAT    = 'F'
 	CMD_GREP      = '&'
+	CMD_FILTER    = 'L'
 	CMD_HELP      = 'h'
 	CMD_MANPAGE   = 'H'
 	CMD_JUMP      = 'j'
 	CMD_MARK      = 'm'
 	CMD_NUMBERS   = '#'
+	CMD_WRAP      = 'w'
+	CMD_HEX       = 'D'
+	CMD_CONTROLS  = 'V'
+	CMD_SYNTAX    = 'y'
+	CMD_LOG       = 'l'
+	CMD_STATUS    = 'T'
+	CMD_TABLE     = '|'
+	CMD_COLUMNS   = 'k'
+	CMD_EXPAND    = 'E'
 	CMD_FILEPOS   = '%'
 	CMD_FILEPOS_1 = '='
 	CMD_FILEPOS_2 = '\007'
@@ -129,7 +151,8 @@ func commands(br *browseObj) {
 		searchCompileErr = err
 		br.pattern = ""
 		br.re = nil
-		br.replace = ""
+		br.setSearchField(nil)
+		br.hexNeedle = nil
 	}
 
 	// wait for a full page
@@ -156,22 +179,53 @@ func commands(br *browseObj) {
 	// searchDir controls the direction of search operations
 	var searchDir bool = SEARCH_FWD
 
+	// a search handed over from another file goes on here
+	if br.handoff != nil {
+		searchDir = br.resumeSearch()
+	}
+
 	// handle panic
 	defer handlePanic(br)
 
 	b := make([]byte, 6) // max length of any key press
+	pressed := false
 
 	for {
+		// the other pane of a split screen goes on between keys
+
+		br.syncSplit(pressed)
+
+		// a search that ran off the end of the file goes on in the next one
+
+		if br.handoff != nil {
+			br.leaveForSearch()
+			return
+		}
+
+		br.drawStatus()
+
 		// scan for input -- compare full escape sequences
 
 		for i := range b {
 			b[i] = 0
 		}
 		n, err := br.tty.Read(b)
+		pressed = err == nil && n > 0
 
 		// continuous modes
 
 		if err != nil || n == 0 {
+			searching, filtering, lastRow := br.search != nil, br.filtering != nil, br.lastRow
+
+			// a grep that finished has shown its results
+			if searching && br.pollSearch() {
+				return
+			}
+			if filtering {
+				br.pollFilter()
+			}
+			br.syncMatchCount()
+
 			switch br.modeScroll {
 
 			case MODE_SCROLL_UP:
@@ -191,9 +245,62 @@ func commands(br *browseObj) {
 				br.scrollDown(SCROLL_CONT)
 			}
 
+			// the EOF marker clears the screen below it, status line too
+			if br.lastRow != lastRow || (searching && br.search == nil) || (filtering && br.filtering == nil) {
+				br.statusShown = ""
+			}
+
 			continue
 		}
 
+		// commands may clear the status line
+
+		br.statusShown = ""
+
+		// a running search or filter takes only its cancel keys
+
+		if br.search != nil {
+			if br.searchKey(b[:n]) {
+				return
+			}
+			continue
+		}
+
+		if br.filtering != nil {
+			br.filterKey(b[:n])
+			continue
+		}
+
+		// a pending match report is stale once another key is pressed
+
+		if br.count != nil {
+			br.count.report = -1
+		}
+
+		// directory listings select entries with the arrows and Enter
+
+		if br.dirList != nil {
+			switch string(b[:n]) {
+
+			case VK_UP:
+				br.modeScroll = MODE_SCROLL_NONE
+				br.moveDirCursor(-1)
+				continue
+
+			case VK_DOWN:
+				br.modeScroll = MODE_SCROLL_NONE
+				br.moveDirCursor(1)
+				continue
+
+			case string(CMD_SCROLL_DN_1):
+				br.modeScroll = MODE_SCROLL_NONE
+				if br.openDirEntry() {
+					return
+				}
+				continue
+			}
+		}
+
 		// convert arrow and page keys to commands
 
 		switch string(b[:n]) {
@@ -262,6 +369,12 @@ func commands(br *browseObj) {
 				// toggle follow mode
 				br.toggleMode(MODE_SCROLL_FOLLOW)
 
+			case CMD_SEARCH_FWD, CMD_SEARCH_REV, CMD_SEARCH_NEXT, CMD_SEARCH_NEXT_REV:
+				// searches stop scroll modes when they find a match
+
+			case CMD_SPLIT_FOCUS:
+				// a pane keeps scrolling without the focus
+
 			default:
 				br.modeScroll = MODE_SCROLL_NONE
 
@@ -273,6 +386,25 @@ func commands(br *browseObj) {
 			}
 		}
 
+		// columns shift a column at a time
+
+		if br.tabular() && isShiftCommand(b[0]) {
+			br.shiftColumns(b[0])
+			continue
+		}
+
+		// hex rows and wrapped lines have nothing to shift
+
+		if br.modeHex && isShiftCommand(b[0]) {
+			br.printMessage("Hex rows fit the screen", MSG_ORANGE)
+			continue
+		}
+
+		if br.wrapping() && isShiftCommand(b[0]) {
+			br.printMessage("Long lines are wrapped", MSG_ORANGE)
+			continue
+		}
+
 		// commands
 
 		switch b[0] {
@@ -300,7 +432,7 @@ func commands(br *browseObj) {
 
 		case CMD_PAGE_UP:
 			// page backward/up
-			if br.firstRow > 0 {
+			if br.firstRow > 0 || br.firstSeg > 0 {
 				br.pageUp()
 			} else {
 				moveCursor(2, 1, false)
@@ -320,7 +452,7 @@ func commands(br *browseObj) {
 
 		case CMD_SHIFT_RIGHT, CMD_SHIFT_RIGHT_1:
 			// horizontal scroll right
-			if br.shiftWidth < (READBUFSIZ - (TABWIDTH * 2)) {
+			if br.shiftWidth < (MAXLINESIZ - (TABWIDTH * 2)) {
 				br.shiftWidth += TABWIDTH
 				br.pageCurrent()
 			}
@@ -352,6 +484,42 @@ func commands(br *browseObj) {
 			br.modeNumbers = !br.modeNumbers
 			br.pageCurrent()
 
+		case CMD_WRAP:
+			// wrap or clip long lines
+			br.toggleWrap()
+
+		case CMD_HEX:
+			// hex dump or text
+			br.toggleHex()
+
+		case CMD_CONTROLS:
+			// raw, color or caret controls
+			br.cycleCtrlMode()
+
+		case CMD_SYNTAX:
+			// color source and config files
+			br.toggleSyntax()
+
+		case CMD_LOG:
+			// log coloring style
+			br.cycleLogStyle()
+
+		case CMD_TABLE:
+			br.toggleTable()
+
+		case CMD_COLUMNS:
+			br.columnsCommand()
+
+		case CMD_EXPAND:
+			// the current record, pretty-printed
+			if br.expandRecord() {
+				return
+			}
+
+		case CMD_STATUS:
+			// status line
+			br.toggleStatus()
+
 		case CMD_MODE_TAIL, CMD_MODE_FOLLOW:
 			// tail or follow
 			br.pageLast()
@@ -360,7 +528,11 @@ func commands(br *browseObj) {
 			}
 
 		case CMD_JUMP:
-			// jump to line
+			// jump to line, or byte offset in hex
+			if br.modeHex {
+				br.jumpOffset()
+				break
+			}
 			lbuf, cancelled := br.userInput("Jump: ")
 			if !cancelled && len(lbuf) > 0 {
 				n, err := strconv.Atoi(strings.TrimSpace(lbuf))
@@ -369,7 +541,7 @@ func commands(br *browseObj) {
 				} else if n < 0 {
 					br.printMessage("Line number must be positive", MSG_ORANGE)
 				} else {
-					br.printPage(n)
+					br.printPage(br.viewLine(n))
 				}
 			}
 
@@ -408,6 +580,34 @@ func commands(br *browseObj) {
 				br.printMessage("Regex search", MSG_GREEN)
 			}
 
+		case CMD_SEARCH_SMART:
+			br.smartCase = !br.smartCase
+			br.lastMatch = SEARCH_RESET
+			br.reCompile(br.pattern)
+			if br.smartCase {
+				br.printMessage("Search ignores case unless the pattern has capitals", MSG_GREEN)
+			} else {
+				br.printMessage("Smart case off", MSG_GREEN)
+			}
+
+		case CMD_SEARCH_WORD:
+			br.wholeWord = !br.wholeWord
+			br.lastMatch = SEARCH_RESET
+			br.reCompile(br.pattern)
+			if br.wholeWord {
+				br.printMessage("Whole-word search", MSG_GREEN)
+			} else {
+				br.printMessage("Search matches within words", MSG_GREEN)
+			}
+
+		case CMD_SEARCH_LIST:
+			br.searchList = !br.searchList
+			if br.searchList {
+				br.printMessage("Search continues through the file list", MSG_GREEN)
+			} else {
+				br.printMessage("Search wraps in the current file", MSG_GREEN)
+			}
+
 		case CMD_SEARCH_PRINT:
 			// print the search pattern
 			if len(br.pattern) == 0 {
@@ -419,9 +619,18 @@ func commands(br *browseObj) {
 		case CMD_SEARCH_CLEAR:
 			// clear the search pattern
 			br.re = nil
+			br.setSearchField(nil)
+			br.hexNeedle = nil
 			br.pattern = ""
 			br.printMessage("Search pattern cleared", MSG_GREEN)
 
+		case CMD_SEARCH_PIN:
+			// pin or unpin the search pattern
+			br.togglePin()
+
+		case CMD_SEARCH_UNPIN:
+			br.clearPins()
+
 		case CMD_MARK:
 			// mark page
 			lbuf, cancelled := br.userInput("Mark: ")
@@ -431,7 +640,11 @@ func commands(br *browseObj) {
 					br.printMessage("Invalid mark (use 1-9)", MSG_ORANGE)
 				} else {
 					br.marks[m] = br.firstRow
-					br.printMessage(fmt.Sprintf("Mark %d at line %d", m, br.marks[m]), MSG_GREEN)
+					if br.modeHex {
+						br.printMessage(fmt.Sprintf("Mark %d at offset %#x", m, br.rowOffset(br.firstRow)), MSG_GREEN)
+					} else {
+						br.printMessage(fmt.Sprintf("Mark %d at line %d", m, br.origLine(br.marks[m])), MSG_GREEN)
+					}
 				}
 			}
 
@@ -443,8 +656,14 @@ func commands(br *browseObj) {
 			br.runFormat()
 
 		case CMD_GREP:
-			// grep -nP pattern
-			br.runGrep()
+			// list matching lines
+			if br.runGrep() {
+				return
+			}
+
+		case CMD_FILTER:
+			// show matching lines only
+			br.filterCommand()
 
 		case CMD_HALF_PAGE_DN, CMD_HALF_PAGE_DN_1, CMD_HALF_PAGE_DN_2:
 			// scroll half page forward/down
@@ -467,15 +686,7 @@ func commands(br *browseObj) {
 			br.printMessage(dir, MSG_GREEN)
 
 		case CMD_NEWFILE:
-			resume := browseResumeState{
-				fileName:    br.fileName,
-				absFileName: br.absFileName,
-				title:       br.title,
-				fromStdin:   br.fromStdin,
-				firstRow:    br.firstRow,
-				lastRow:     br.lastRow,
-				shiftWidth:  br.shiftWidth,
-			}
+			resume := br.saveResumeState()
 			if fileCommand(br) {
 				if br.listAction == LIST_ACTION_EXIT_ALL {
 					return
@@ -488,11 +699,23 @@ func commands(br *browseObj) {
 			}
 
 		case CMD_REREAD:
+			if br.dirList != nil {
+				// list the directory again in place
+				br.resume = br.saveResumeState()
+				br.saveRC = false
+				br.listAction = LIST_ACTION_RESUME
+				return
+			}
+			if _, ok := spoolLookup(br.fileName); ok {
+				br.printMessage("Cannot re-read spooled input", MSG_ORANGE)
+				break
+			}
 			br.mutex.Lock()
 			if !br.fromStdin && br.absFileName != "" {
 				br.rereadPending = true
 			}
 			br.mutex.Unlock()
+			br.wakeReader()
 
 		case CMD_REWIND:
 			if br.fromStdin {
@@ -543,6 +766,26 @@ func commands(br *browseObj) {
 			br.listAction = LIST_ACTION_EXIT_ALL
 			return
 
+		case CMD_SPLIT, CMD_SPLIT_VERT:
+			// open, close or turn the split screen
+			if br.splitCommand(b[0] == CMD_SPLIT_VERT) {
+				return
+			}
+
+		case CMD_SPLIT_FOCUS:
+			if br.switchPane() {
+				return
+			}
+
+		case CMD_SPLIT_GROW:
+			br.resizePane(1)
+
+		case CMD_SPLIT_SHRINK:
+			br.resizePane(-1)
+
+		case CMD_SPLIT_LOCK:
+			br.toggleScrollLock()
+
 		case CMD_HELP:
 			// help
 			br.printHelp()
@@ -651,15 +894,25 @@ func fileCommand(br *browseObj) bool {
 		return false
 	}
 
+	if allFiles := br.fileArgs(newFile); len(allFiles) > 0 {
+		if processFileList(br, allFiles, false) {
+			return true
+		}
+	}
+
+	br.pageCurrent()
+	return false
+}
+
+// fileArgs expands the answer to a file prompt into file names: % is the
+// current file, - and # the previous one, and globs and archive members
+// are expanded.
+func (br *browseObj) fileArgs(input string) []string {
 	// Split first so a substituted filename with spaces stays one token.
-	tokens := fieldsQuoted(newFile)
+	tokens := fieldsQuoted(input)
 	for i, tok := range tokens {
 		tokens[i] = subCommandChars(tok, "%", br.fileName)
 	}
-	if len(tokens) == 0 {
-		br.pageCurrent()
-		return false
-	}
 
 	// Pre-allocate with estimated capacity
 	allFiles := make([]string, 0, len(tokens))
@@ -673,13 +926,21 @@ func fileCommand(br *browseObj) bool {
 			if len(history) < 2 {
 				br.userAnyKey(fmt.Sprintf("%s No previous file ... [press any key] %s",
 					MSG_RED, VIDOFF))
-				br.pageCurrent()
-				return false
+				return nil
 			}
 
 			tok = unQuote(history[len(history)-2])
 		}
 
+		// archive::glob, or a bare glob in an archive listing, names members
+		if members, ok := br.expandMembers(tok); ok {
+			if len(members) == 0 {
+				br.timedMessage(fmt.Sprintf("No members match pattern: %s", tok), MSG_ORANGE)
+			}
+			allFiles = append(allFiles, members...)
+			continue
+		}
+
 		// Expand globs for each token
 		if strings.ContainsAny(tok, "*?[") {
 			files, err := filepath.Glob(tok)
@@ -697,17 +958,7 @@ func fileCommand(br *browseObj) bool {
 		}
 	}
 
-	if len(allFiles) > 0 {
-		if processFileList(br, allFiles, false) {
-			return true
-		}
-
-		br.pageCurrent()
-		return false
-	}
-
-	br.pageCurrent()
-	return false
+	return allFiles
 }
 
 // fieldsQuoted splits a string into fields, preserving quoted substrings.
@@ -833,7 +1084,7 @@ func shiftLongest(br *browseObj) int {
 		if line == nil {
 			continue
 		}
-		lineLength := utf8.RuneCount(line)
+		lineLength := textColumns(line)
 
 		if lineLength > longest {
 			longest = lineLength
@@ -851,6 +1102,18 @@ func shiftLongest(br *browseObj) int {
 	return ((longest - br.dispWidth + TABWIDTH) / TABWIDTH) * TABWIDTH
 }
 
+// isShiftCommand reports whether a key scrolls horizontally.
+func isShiftCommand(key byte) bool {
+	switch key {
+
+	case CMD_SHIFT_LEFT, CMD_SHIFT_LEFT_1, CMD_SHIFT_LEFT_2,
+		CMD_SHIFT_RIGHT, CMD_SHIFT_RIGHT_1, CMD_SHIFT_ZERO, CMD_SHIFT_LONGEST:
+		return true
+	}
+
+	return false
+}
+
 // handlePanic recovers from panics and exits cleanly.
 func handlePanic(br *browseObj) {
 	if r := recover(); r != nil {
@@ -876,10 +1139,36 @@ func filePosition(br *browseObj) {
 		t = float32(br.firstRow) / float32(lineCount) * 100.0
 	}
 
-	dispName := abbreviateFileName(br.fileName, br.dispWidth>>1)
+	dispName := abbreviateFileName(br.sourceName(), br.dispWidth>>1)
+
+	if br.modeHex {
+		br.mutex.Lock()
+		fileSize := br.savFileSiz
+		br.mutex.Unlock()
+
+		br.printMessage(fmt.Sprintf("\"%s\" %d bytes --%1.1f%%--",
+			dispName, fileSize, t), MSG_GREEN)
+		return
+	}
+
+	// note filtered views and converted text
+	detail := ""
+
+	br.mutex.Lock()
+	if br.filter != nil {
+		detail = fmt.Sprintf(", %d shown", lineCount)
+		lineCount = br.scanLines
+	}
+	br.mutex.Unlock()
+
+	detail += br.matchTotal()
+
+	if br.encoding != ENC_UTF8 {
+		detail += " " + encodingName(br.encoding)
+	}
 
-	br.printMessage(fmt.Sprintf("\"%s\" %d lines --%1.1f%%--",
-		dispName, lineCount, t), MSG_GREEN)
+	br.printMessage(fmt.Sprintf("\"%s\" %d lines%s --%1.1f%%--",
+		dispName, lineCount, detail, t), MSG_GREEN)
 }
 
 // vim: set ts=4 sw=4 noet:
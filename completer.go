@@ -39,6 +39,10 @@ const (
 // SearchType controls which completion mode is active.
 var SearchType int
 
+// searchTyped, when set, is called as a search pattern is edited, with the
+// whole pattern and the part before the cursor.
+var searchTyped func(text, beforeCursor string)
+
 type completionCandidate struct {
 	name       string
 	suggestion prompt.Suggest
@@ -71,21 +75,30 @@ func userBashComp() (string, bool) {
 	return runCompleter(shellPrompt(), commHistory)
 }
 
-// userSearchComp prompts for a search pattern with completion.
-func userSearchComp(searchDir bool) (string, bool) {
-	promptStr := "/"
+// userSearchComp prompts for a search pattern with completion. typed, if
+// not nil, follows the pattern as it is edited.
+func userSearchComp(searchDir bool, typed func(text, beforeCursor string)) (string, bool) {
+	SearchType = searchSearch
+	searchTyped = typed
+	defer func() { searchTyped = nil }()
+
+	return runCompleter(searchPrompt(searchDir), searchHistory)
+}
+
+// searchPrompt returns the prompt for a search direction.
+func searchPrompt(searchDir bool) string {
 	if !searchDir {
-		promptStr = "?"
+		return "?"
 	}
 
-	SearchType = searchSearch
-	return runCompleter(promptStr, searchHistory)
+	return "/"
 }
 
 // runCompleter starts the prompt UI and returns user input and cancellation state.
 func runCompleter(promptStr, historyFile string) (string, bool) {
 	history := loadHistory(historyFile)
 	pathCache = pathCompletionCache{}
+	promptShown = true
 
 	// reset go-prompt BackedOut flag
 	prompt.BackedOut = false
@@ -144,7 +157,7 @@ func completer(d prompt.Document) []prompt.Suggest {
 	switch SearchType {
 
 	case searchSearch:
-		return searchCompleter()
+		return searchCompleter(d)
 
 	case searchDirs:
 		return dirCompleter(word)
@@ -202,7 +215,12 @@ func hasPathSeparator(word string) bool {
 }
 
 // searchCompleter returns suggestions for search history (currently none).
-func searchCompleter() []prompt.Suggest {
+// It runs on every edit, so it also drives incremental search.
+func searchCompleter(d prompt.Document) []prompt.Suggest {
+	if searchTyped != nil {
+		searchTyped(d.Text, d.TextBeforeCursor())
+	}
+
 	return nil
 }
 
@@ -0,0 +1,196 @@
+// controls.go
+// show control characters raw, as colors, or in caret notation
+//
+// Copyright (c) 2024-2026 jjb
+// All rights reserved.
+//
+// This source code is licensed under the MIT license found
+// in the root directory of this source tree.
+
+package main
+
+import (
+	"bytes"
+	"strings"
+)
+
+// Control character display modes.
+const (
+	// control bytes go to the terminal as they are
+	CTRL_RAW = iota
+
+	// SGR color sequences are interpreted, other controls dropped
+	CTRL_COLOR
+
+	// control bytes are shown as ^X
+	CTRL_CARET
+)
+
+// ctrlModeNames are the mode names shown when the mode changes.
+var ctrlModeNames = []string{
+	CTRL_RAW:   "Control characters passed through",
+	CTRL_COLOR: "ANSI colors shown, other controls hidden",
+	CTRL_CARET: "Control characters shown as ^X",
+}
+
+// sgrSpan is an SGR sequence that takes effect at a byte offset of the
+// visible text.
+type sgrSpan struct {
+	pos int
+	sgr string
+}
+
+// cycleCtrlMode moves to the next control mode. Filters and match counts
+// see the visible text, so the file is mapped again.
+func (br *browseObj) cycleCtrlMode() {
+	if !br.remapFile(func() {
+		br.ctrlMode = (br.ctrlMode + 1) % len(ctrlModeNames)
+	}) {
+		return
+	}
+
+	br.shiftWidth = 0
+	br.pageCurrent()
+	br.printMessage(ctrlModeNames[br.ctrlMode], MSG_GREEN)
+}
+
+// visibleText returns a decoded line as it is shown in a control mode, with
+// tabs expanded. In CTRL_COLOR it also returns the color changes, which
+// search and shifting never see. Lines without control bytes come back
+// as expandTabs returns them.
+func visibleText(line []byte, mode int) ([]byte, []sgrSpan) {
+	if mode == CTRL_RAW || !hasControls(line) {
+		return expandTabs(line), nil
+	}
+
+	var buf bytes.Buffer
+	var spans []sgrSpan
+	buf.Grow(len(line) + TABWIDTH)
+
+	if mode == CTRL_CARET {
+		// CRLF line endings are not worth a ^M on every line
+		line = bytes.TrimSuffix(line, []byte{'\r'})
+	}
+
+	cols := 0
+	for i := 0; i < len(line); i++ {
+		b := line[i]
+
+		switch {
+
+		case b == '\t':
+			spaces := TABWIDTH - cols%TABWIDTH
+			buf.Write(tabSpaces[:spaces])
+			cols += spaces
+
+		case !isControl(b):
+			size, w := nextRune(line[i:])
+			buf.Write(line[i : i+size])
+			cols += w
+			i += size - 1
+
+		case mode == CTRL_CARET:
+			buf.WriteByte('^')
+			buf.WriteByte(b ^ 0x40)
+			cols += 2
+
+		case b == '\033':
+			n, sgr := escapeSequence(line[i:])
+			if sgr {
+				spans = append(spans, sgrSpan{pos: buf.Len(), sgr: string(line[i : i+n])})
+			}
+			i += n - 1
+		}
+	}
+
+	return buf.Bytes(), spans
+}
+
+// visibleLine decodes a line and returns its visible text.
+func visibleLine(data []byte, offset int64, encoding, mode int) []byte {
+	text, _ := visibleText(decodeLine(data, offset, encoding), mode)
+	return text
+}
+
+// isControl reports whether a byte is a C0 control or DEL.
+func isControl(b byte) bool {
+	return b < ' ' || b == 0x7f
+}
+
+// hasControls reports whether a line has control bytes other than tabs.
+func hasControls(line []byte) bool {
+	for _, b := range line {
+		if isControl(b) && b != '\t' {
+			return true
+		}
+	}
+
+	return false
+}
+
+// escapeSequence returns the length of the escape sequence at the start
+// of text, and whether it is an SGR sequence. CSI and OSC sequences are
+// recognized; other escapes take the byte after ESC with them.
+func escapeSequence(text []byte) (int, bool) {
+	if len(text) < 2 {
+		return len(text), false
+	}
+
+	switch text[1] {
+
+	case '[':
+		// CSI: parameters and intermediates, then a final byte
+		for i := 2; i < len(text); i++ {
+			if text[i] >= 0x40 && text[i] <= 0x7e {
+				return i + 1, text[i] == 'm'
+			}
+
+			if text[i] < 0x20 || text[i] > 0x3f {
+				return i, false
+			}
+		}
+
+		return len(text), false
+
+	case ']':
+		// OSC: ends with BEL or ESC backslash
+		for i := 2; i < len(text); i++ {
+			if text[i] == '\007' {
+				return i + 1, false
+			}
+
+			if text[i] == '\033' && i+1 < len(text) && text[i+1] == '\\' {
+				return i + 2, false
+			}
+		}
+
+		return len(text), false
+	}
+
+	return 2, false
+}
+
+// isSGRReset reports whether an SGR sequence resets all attributes.
+func isSGRReset(sgr string) bool {
+	params := strings.TrimSuffix(strings.TrimPrefix(sgr, "\033["), "m")
+	return strings.Trim(params, "0") == ""
+}
+
+// sgrState returns the SGR sequences in effect at a byte offset, and the
+// index of the first span after it.
+func sgrState(spans []sgrSpan, pos int) (string, int) {
+	state := ""
+
+	i := 0
+	for ; i < len(spans) && spans[i].pos <= pos; i++ {
+		if isSGRReset(spans[i].sgr) {
+			state = ""
+		} else {
+			state += spans[i].sgr
+		}
+	}
+
+	return state, i
+}
+
+// vim: set ts=4 sw=4 noet:
@@ -0,0 +1,263 @@
+// decompress.go
+// detect and decompress compressed input
+//
+// Copyright (c) 2024-2026 jjb
+// All rights reserved.
+//
+// This source code is licensed under the MIT license found
+// in the root directory of this source tree.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+)
+
+// Compression formats recognized by magic number.
+const (
+	COMPRESS_NONE = iota
+	COMPRESS_GZIP
+	COMPRESS_BZIP2
+	COMPRESS_XZ
+	COMPRESS_ZSTD
+)
+
+// compressMagic lists the leading bytes of each supported format.
+var compressMagic = []struct {
+	kind  int
+	magic []byte
+	name  string
+}{
+	{COMPRESS_GZIP, []byte{0x1f, 0x8b}, "gzip"},
+	{COMPRESS_BZIP2, []byte("BZh"), "bzip2"},
+	{COMPRESS_XZ, []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}, "xz"},
+	{COMPRESS_ZSTD, []byte{0x28, 0xb5, 0x2f, 0xfd}, "zstd"},
+}
+
+// bzip2Blocks are the magic numbers of a bzip2 block and of the end of
+// the stream, one of which follows the header.
+var bzip2Blocks = [][]byte{
+	{0x31, 0x41, 0x59, 0x26, 0x53, 0x59},
+	{0x17, 0x72, 0x45, 0x38, 0x50, 0x90},
+}
+
+// maxMagicLen is the number of bytes needed to identify any format.
+const maxMagicLen = 10
+
+// compressionType identifies the compression format from leading bytes.
+func compressionType(head []byte) int {
+	for _, m := range compressMagic {
+		if !bytes.HasPrefix(head, m.magic) {
+			continue
+		}
+
+		// "BZh" is common enough in text that bzip2 needs the block size
+		// and the block magic too
+		if m.kind == COMPRESS_BZIP2 && !bzip2Header(head) {
+			continue
+		}
+
+		return m.kind
+	}
+
+	return COMPRESS_NONE
+}
+
+// magicPrefix reports whether head is too short to tell, but could start
+// the header of a format.
+func magicPrefix(head []byte) bool {
+	if len(head) == 0 || len(head) >= maxMagicLen {
+		return false
+	}
+
+	for _, m := range compressMagic {
+		n := min(len(head), len(m.magic))
+		if bytes.Equal(head[:n], m.magic[:n]) && (n < len(m.magic) || m.kind == COMPRESS_BZIP2) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// bzip2Header reports whether head starts with a whole bzip2 header: BZh,
+// a block size digit and the magic of the first block.
+func bzip2Header(head []byte) bool {
+	if len(head) < maxMagicLen || head[3] < '1' || head[3] > '9' {
+		return false
+	}
+
+	for _, block := range bzip2Blocks {
+		if bytes.Equal(head[4:maxMagicLen], block) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// compressionName returns the external command name for a format.
+func compressionName(kind int) string {
+	for _, m := range compressMagic {
+		if m.kind == kind {
+			return m.name
+		}
+	}
+
+	return ""
+}
+
+// compressionTypeFp identifies the compression format of an open file.
+func compressionTypeFp(fp *os.File) int {
+	head := make([]byte, maxMagicLen)
+
+	n, err := fp.ReadAt(head, 0)
+	if err != nil && err != io.EOF {
+		return COMPRESS_NONE
+	}
+
+	return compressionType(head[:n])
+}
+
+// cmdReader reads the output of an external decompressor.
+type cmdReader struct {
+	io.ReadCloser
+	cmd *exec.Cmd
+}
+
+// Close stops the decompressor and reaps it.
+func (c *cmdReader) Close() error {
+	c.ReadCloser.Close()
+	c.cmd.Process.Kill()
+
+	return c.cmd.Wait()
+}
+
+// multiCloser closes a decompressor and the stream beneath it.
+type multiCloser struct {
+	io.Reader
+	closers []io.Closer
+}
+
+// Close closes each underlying stream in order.
+func (m *multiCloser) Close() error {
+	var first error
+
+	for _, c := range m.closers {
+		if err := c.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+
+	return first
+}
+
+// newDecompressor wraps src in a reader for the given format. Formats
+// without a standard library reader are piped through the external tool.
+func newDecompressor(kind int, src io.Reader) (io.ReadCloser, error) {
+	switch kind {
+
+	case COMPRESS_GZIP:
+		return gzip.NewReader(src)
+
+	case COMPRESS_BZIP2:
+		return io.NopCloser(bzip2.NewReader(src)), nil
+
+	case COMPRESS_XZ, COMPRESS_ZSTD:
+		name := compressionName(kind)
+		cmdPath, err := exec.LookPath(name)
+		if err != nil {
+			return nil, fmt.Errorf("cannot find '%s' in $PATH", name)
+		}
+
+		cmd := exec.Command(cmdPath, "-dc")
+		cmd.Stdin = src
+		stdout, err := cmd.StdoutPipe()
+		if err != nil {
+			return nil, err
+		}
+
+		if err := cmd.Start(); err != nil {
+			return nil, err
+		}
+
+		return &cmdReader{ReadCloser: stdout, cmd: cmd}, nil
+	}
+
+	return io.NopCloser(src), nil
+}
+
+// openDecompressed returns src, decompressed if its magic number is known.
+// If the decompressor cannot start, the raw stream is returned with the error.
+func openDecompressed(src io.Reader) (io.ReadCloser, error) {
+	bufReader := bufio.NewReader(src)
+
+	// a short or empty stream is not compressed; a pipe is read further
+	// only while what has come so far could start a magic number
+	head, _ := bufReader.Peek(1)
+	if head, _ = bufReader.Peek(min(bufReader.Buffered(), maxMagicLen)); magicPrefix(head) {
+		head, _ = bufReader.Peek(maxMagicLen)
+	}
+
+	kind := compressionType(head)
+	if kind == COMPRESS_NONE {
+		return io.NopCloser(bufReader), nil
+	}
+
+	if kind == COMPRESS_GZIP {
+		// gzip.NewReader consumes the header, so try it on what has been
+		// read; a header cut short is left to the real reader
+		head, _ := bufReader.Peek(bufReader.Buffered())
+		if _, err := gzip.NewReader(bytes.NewReader(head)); err != nil && err != io.ErrUnexpectedEOF {
+			return io.NopCloser(bufReader), err
+		}
+	}
+
+	rc, err := newDecompressor(kind, bufReader)
+	if err != nil {
+		return io.NopCloser(bufReader), err
+	}
+
+	return rc, nil
+}
+
+// spoolCompressed decompresses a file into a spool for browsing.
+// It returns nil when the file is not compressed.
+func spoolCompressed(br *browseObj, fp *os.File, fileName string) *spoolObj {
+	const primeSize = 4 * 1024
+
+	kind := compressionTypeFp(fp)
+	if kind == COMPRESS_NONE {
+		return nil
+	}
+
+	src, err := os.Open(fileName)
+	if err != nil {
+		return nil
+	}
+
+	rc, err := newDecompressor(kind, src)
+	if err != nil {
+		src.Close()
+		br.timedMessage(fmt.Sprintf("Cannot decompress: %v", err), MSG_ORANGE)
+		return nil
+	}
+
+	sp, err := newSpool(&multiCloser{Reader: rc, closers: []io.Closer{rc, src}},
+		fileName, primeSize)
+	if err != nil {
+		br.timedMessage(err.Error(), MSG_ORANGE)
+		return nil
+	}
+
+	return sp
+}
+
+// vim: set ts=4 sw=4 noet:
@@ -0,0 +1,226 @@
+// dirlist.go
+// browse directories as a listing of their entries
+//
+// Copyright (c) 2024-2026 jjb
+// All rights reserved.
+//
+// This source code is licensed under the MIT license found
+// in the root directory of this source tree.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// dirListing holds the entries shown by a directory or archive listing.
+// Line n of the listing is entries[n-1]. Grep results list lines instead:
+// line n of the results is line lines[n-1] of the file searched.
+type dirListing struct {
+	path     string
+	entries  []string
+	archive  bool
+	lines    []int
+	selected int
+}
+
+// size returns the number of entries or result lines.
+func (l *dirListing) size() int {
+	if l.lines != nil {
+		return len(l.lines)
+	}
+
+	return len(l.entries)
+}
+
+// entryPath returns the path that opens a listing entry.
+func (l *dirListing) entryPath(i int) string {
+	if l.archive {
+		return l.path + MEMBER_SEP + l.entries[i]
+	}
+
+	return filepath.Join(l.path, l.entries[i])
+}
+
+// listDirectory spools a listing of a directory, one entry per line, with
+// its mode, size, modification time and name.
+func listDirectory(fp *os.File, dirName string) (*spoolObj, *dirListing, error) {
+	names, err := fp.Readdirnames(-1)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// byte order, like ls in the C locale
+	sort.Strings(names)
+	if filepath.Dir(dirName) != dirName {
+		names = append([]string{".."}, names...)
+	}
+
+	listing := &dirListing{path: dirName}
+
+	var buf bytes.Buffer
+	for _, name := range names {
+		entryPath := filepath.Join(dirName, name)
+		info, err := os.Lstat(entryPath)
+		if err != nil {
+			continue
+		}
+
+		var link string
+		if info.Mode()&fs.ModeSymlink != 0 {
+			link, _ = os.Readlink(entryPath)
+		}
+
+		listing.entries = append(listing.entries, name)
+		buf.WriteString(formatDirEntry(name, info, link))
+		buf.WriteByte('\n')
+	}
+
+	sp, err := newSpool(io.NopCloser(&buf), dirName, buf.Len())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return sp, listing, nil
+}
+
+// formatDirEntry formats one line of a listing, ls -l style. The link
+// target is shown for symlinks.
+func formatDirEntry(name string, info fs.FileInfo, link string) string {
+	mode := info.Mode()
+
+	// mark the type after the name
+	display := printableName(name)
+	switch {
+
+	case mode.IsDir():
+		display += "/"
+
+	case mode&fs.ModeSymlink != 0:
+		if link != "" {
+			display += " -> " + printableName(link)
+		}
+
+	case mode&fs.ModeNamedPipe != 0:
+		display += "|"
+
+	case mode&fs.ModeSocket != 0:
+		display += "="
+
+	case mode&0111 != 0:
+		display += "*"
+	}
+
+	return fmt.Sprintf("%s %6s  %s  %s", mode.String(), humanSize(info.Size()),
+		info.ModTime().Format("2006-01-02 15:04"), display)
+}
+
+// printableName replaces control characters that would break a listing line.
+func printableName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r < ' ' || r == 0x7f {
+			return '?'
+		}
+		return r
+	}, name)
+}
+
+// humanSize formats a byte count in at most five columns.
+func humanSize(size int64) string {
+	const units = "KMGTPE"
+
+	if size < 1024 {
+		return fmt.Sprintf("%d", size)
+	}
+
+	value := float64(size)
+	for i := range len(units) {
+		value /= 1024
+		if value < 10 {
+			return fmt.Sprintf("%.1f%c", value, units[i])
+		}
+		if value < 1024 || i == len(units)-1 {
+			return fmt.Sprintf("%.0f%c", value, units[i])
+		}
+	}
+
+	return ""
+}
+
+// dirCursorBounds returns the range of listing lines on the current page.
+func (br *browseObj) dirCursorBounds() (int, int) {
+	return max(br.firstRow, 1), min(br.pageEnd(), br.currentMapSize())
+}
+
+// moveDirCursor moves the listing cursor by delta lines, bringing it back
+// to the page first if paging or a jump left it behind.
+func (br *browseObj) moveDirCursor(delta int) {
+	first, end := br.dirCursorBounds()
+	if end <= first {
+		moveCursor(2, 1, false)
+		return
+	}
+
+	if br.dirCursor < first || br.dirCursor >= end {
+		br.dirCursor = first
+	} else {
+		br.dirCursor = min(max(br.dirCursor+delta, 1), br.currentMapSize()-1)
+	}
+
+	top := br.firstRow
+	if br.dirCursor < first {
+		top = br.dirCursor
+	} else if br.dirCursor >= end {
+		top = br.dirCursor - br.dispRows + 1
+	}
+
+	// redraw in place so the old cursor line is repainted
+	br.firstRow = adjustLineNumber(top, br.dispRows, br.currentMapSize())
+	br.printPage(br.firstRow)
+}
+
+// openDirEntry browses the entry under the cursor as a nested list. It
+// returns true when the listing should be re-read on return, as with B.
+// In grep results it selects the line under the cursor and returns true
+// to leave the results.
+func (br *browseObj) openDirEntry() bool {
+	first, end := br.dirCursorBounds()
+	if br.dirCursor < first || br.dirCursor >= end || br.dirCursor > br.dirList.size() {
+		br.moveDirCursor(0)
+		return false
+	}
+
+	if br.dirList.lines != nil {
+		br.dirList.selected = br.dirList.lines[br.origLine(br.dirCursor)-1]
+		br.saveRC = false
+		br.exit = true
+		return true
+	}
+
+	target := br.dirList.entryPath(br.origLine(br.dirCursor) - 1)
+
+	resume := br.saveResumeState()
+	if !processFileList(br, []string{target}, false) {
+		br.pageCurrent()
+		return false
+	}
+
+	if br.listAction == LIST_ACTION_EXIT_ALL {
+		return true
+	}
+
+	br.resume = resume
+	restoreResumeState(br)
+	br.saveRC = false
+	br.listAction = LIST_ACTION_RESUME
+	return true
+}
+
+// vim: set ts=4 sw=4 noet:
@@ -0,0 +1,262 @@
+// encoding.go
+// detect and convert UTF-16 and Latin-1 text
+//
+// Copyright (c) 2024-2026 jjb
+// All rights reserved.
+//
+// This source code is licensed under the MIT license found
+// in the root directory of this source tree.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Character encodings.
+const (
+	ENC_AUTO = iota
+	ENC_UTF8
+	ENC_LATIN1
+	ENC_UTF16LE
+	ENC_UTF16BE
+)
+
+// Byte order marks.
+var (
+	bomUTF8    = []byte{0xef, 0xbb, 0xbf}
+	bomUTF16LE = []byte{0xff, 0xfe}
+	bomUTF16BE = []byte{0xfe, 0xff}
+)
+
+// encodingNames maps --encoding values to encodings.
+var encodingNames = map[string]int{
+	"utf-8":      ENC_UTF8,
+	"utf8":       ENC_UTF8,
+	"latin1":     ENC_LATIN1,
+	"latin-1":    ENC_LATIN1,
+	"iso-8859-1": ENC_LATIN1,
+	"iso8859-1":  ENC_LATIN1,
+	"utf-16le":   ENC_UTF16LE,
+	"utf16le":    ENC_UTF16LE,
+	"utf-16be":   ENC_UTF16BE,
+	"utf16be":    ENC_UTF16BE,
+}
+
+// parseEncoding returns the encoding for an --encoding value.
+func parseEncoding(name string) (int, error) {
+	if enc, ok := encodingNames[strings.ToLower(name)]; ok {
+		return enc, nil
+	}
+
+	return ENC_AUTO, fmt.Errorf("unknown encoding: %s", name)
+}
+
+// encodingName returns the display name of an encoding.
+func encodingName(enc int) string {
+	switch enc {
+
+	case ENC_LATIN1:
+		return "Latin-1"
+
+	case ENC_UTF16LE:
+		return "UTF-16LE"
+
+	case ENC_UTF16BE:
+		return "UTF-16BE"
+	}
+
+	return "UTF-8"
+}
+
+// isUTF16 reports whether an encoding uses 16-bit code units.
+func isUTF16(enc int) bool {
+	return enc == ENC_UTF16LE || enc == ENC_UTF16BE
+}
+
+// detectEncoding guesses the encoding of a file from its head.
+func detectEncoding(fp *os.File) int {
+	const sampleSize = 4 * 1024
+	buffer := make([]byte, sampleSize)
+
+	bytesRead, err := fp.ReadAt(buffer, 0)
+	if err != nil && err != io.EOF {
+		return ENC_UTF8
+	}
+
+	return sampleEncoding(buffer[:bytesRead])
+}
+
+// sampleEncoding guesses an encoding from a BOM, or from the pattern of
+// NUL bytes and invalid UTF-8 in a sample.
+func sampleEncoding(sample []byte) int {
+	switch {
+
+	case bytes.HasPrefix(sample, bomUTF8):
+		return ENC_UTF8
+
+	case bytes.HasPrefix(sample, bomUTF16LE):
+		return ENC_UTF16LE
+
+	case bytes.HasPrefix(sample, bomUTF16BE):
+		return ENC_UTF16BE
+	}
+
+	// mostly-ASCII UTF-16 has a NUL in one half of most code units
+	var zeros [2]int
+	units := len(sample) / 2
+
+	for i, c := range sample[:units*2] {
+		if c == 0 {
+			zeros[i&1]++
+		}
+	}
+
+	if units >= 2 {
+		if zeros[1] > units/2 && zeros[0] < units/16 {
+			return ENC_UTF16LE
+		}
+
+		if zeros[0] > units/2 && zeros[1] < units/16 {
+			return ENC_UTF16BE
+		}
+	}
+
+	// drop a character cut off by the end of the sample
+	for i := len(sample) - 1; i >= max(len(sample)-utf8.UTFMax, 0); i-- {
+		if utf8.RuneStart(sample[i]) {
+			if !utf8.FullRune(sample[i:]) {
+				sample = sample[:i]
+			}
+			break
+		}
+	}
+
+	if bytes.IndexByte(sample, 0) < 0 && !utf8.Valid(sample) {
+		return ENC_LATIN1
+	}
+
+	return ENC_UTF8
+}
+
+// encodedNewline returns the line terminator in an encoding.
+func encodedNewline(enc int) string {
+	switch enc {
+
+	case ENC_UTF16LE:
+		return "\n\x00"
+
+	case ENC_UTF16BE:
+		return "\x00\n"
+	}
+
+	return "\n"
+}
+
+// readEncodedLine reads through the next newline in an encoding, like
+// ReadString('\n'). A UTF-16 newline must be a whole code unit; a '\n'
+// byte that is half of another character is read past.
+func readEncodedLine(r *bufio.Reader, enc int) (string, error) {
+	if !isUTF16(enc) {
+		return r.ReadString('\n')
+	}
+
+	var line []byte
+
+	for {
+		chunk, err := r.ReadSlice('\n')
+		line = append(line, chunk...)
+
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil {
+			return string(line), err
+		}
+
+		// lines start on a code unit, so the unit's place is its parity
+		end := len(line) - 1
+
+		if enc == ENC_UTF16BE {
+			if end%2 == 1 && line[end-1] == 0 {
+				return string(line), nil
+			}
+			continue
+		}
+
+		if end%2 != 0 {
+			continue
+		}
+
+		next, err := r.Peek(1)
+		if err != nil {
+			return string(line), err
+		}
+
+		if next[0] == 0 {
+			r.ReadByte()
+			return string(append(line, 0)), nil
+		}
+	}
+}
+
+// decodeLine converts a line read at offset seek to UTF-8, dropping a
+// byte order mark at the start of the file.
+func decodeLine(data []byte, seek int64, enc int) []byte {
+	switch enc {
+
+	case ENC_LATIN1:
+		var out []byte
+
+		for i, c := range data {
+			if c >= utf8.RuneSelf {
+				if out == nil {
+					out = append(make([]byte, 0, len(data)*2), data[:i]...)
+				}
+				out = utf8.AppendRune(out, rune(c))
+			} else if out != nil {
+				out = append(out, c)
+			}
+		}
+
+		if out != nil {
+			return out
+		}
+
+	case ENC_UTF16LE, ENC_UTF16BE:
+		units := make([]uint16, len(data)/2)
+		for i := range units {
+			if enc == ENC_UTF16LE {
+				units[i] = uint16(data[2*i]) | uint16(data[2*i+1])<<8
+			} else {
+				units[i] = uint16(data[2*i])<<8 | uint16(data[2*i+1])
+			}
+		}
+
+		if seek == 0 && len(units) > 0 && units[0] == 0xfeff {
+			units = units[1:]
+		}
+
+		out := make([]byte, 0, len(units))
+		for _, r := range utf16.Decode(units) {
+			out = utf8.AppendRune(out, r)
+		}
+
+		return out
+	}
+
+	if seek == 0 {
+		return bytes.TrimPrefix(data, bomUTF8)
+	}
+
+	return data
+}
+
+// vim: set ts=4 sw=4 noet:
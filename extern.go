@@ -26,6 +26,7 @@ const (
 
 // Core limits and defaults.
 const (
+	MAXLINESIZ   = 16 << 20
 	MAXMARKS     = 10
 	READBUFSIZ   = 4096
 	SEARCH_RESET = -1
@@ -41,6 +42,7 @@ const (
 	CURPOS       = "\033[%d;%dH"
 	CURRESTORE   = "\033\070"
 	CURSAVE      = "\r\033\067"
+	CURSAVEHERE  = "\033\067"
 	CURUP        = "\033[A"
 	LINEWRAPOFF  = "\033[?7l"
 	LINEWRAPON   = "\033[?7h"
@@ -82,11 +84,25 @@ const (
 	_VID_GREEN_FG  = "\033[38;5;46m"
 	_VID_ORANGE_FG = "\033[38;5;208m"
 
-	_VID_BLACK_BG  = "\033[48;5;16m"
-	_VID_GREEN_BG  = "\033[48;5;46m"
-	_VID_BLUE_BG   = "\033[48;5;21m"
-	_VID_ORANGE_BG = "\033[48;5;208m"
-	_VID_RED_BG    = "\033[48;5;160m"
+	// softer foregrounds for syntax coloring
+	_VID_BLUE_FG    = "\033[38;5;75m"
+	_VID_YELLOW_FG  = "\033[38;5;222m"
+	_VID_GREY_FG    = "\033[38;5;245m"
+	_VID_MAGENTA_FG = "\033[38;5;176m"
+	_VID_CYAN_FG    = "\033[38;5;80m"
+	_VID_RED_FG     = "\033[38;5;203m"
+
+	_VID_BLACK_BG   = "\033[48;5;16m"
+	_VID_GREEN_BG   = "\033[48;5;46m"
+	_VID_BLUE_BG    = "\033[48;5;21m"
+	_VID_ORANGE_BG  = "\033[48;5;208m"
+	_VID_RED_BG     = "\033[48;5;160m"
+	_VID_YELLOW_BG  = "\033[48;5;226m"
+	_VID_CYAN_BG    = "\033[48;5;51m"
+	_VID_MAGENTA_BG = "\033[48;5;201m"
+	_VID_VIOLET_BG  = "\033[48;5;141m"
+	_VID_PINK_BG    = "\033[48;5;218m"
+	_VID_SKY_BG     = "\033[48;5;117m"
 )
 
 // ─── Meaningful Attribute Groupings ─────────────────────────────────
@@ -97,6 +113,7 @@ const (
 	VIDBLINK   = _VID_BLINK
 	VIDBOLDREV = _VID_BOLD + _VID_REV
 	VIDHELP    = _VID_WHITE_FG + _VID_BLUE_BG
+	VIDSTATUS  = _VID_REV
 
 	MSG_GREEN         = _VID_BOLD + _VID_BLACK_FG + _VID_GREEN_BG
 	MSG_ORANGE        = _VID_BOLD + _VID_BLACK_FG + _VID_ORANGE_BG
@@ -123,6 +140,7 @@ const (
 	LIST_ACTION_REWIND
 	LIST_ACTION_RESUME
 	LIST_ACTION_EXIT_ALL
+	LIST_ACTION_PREV
 )
 
 // ─── Default and Browse History Files ───────────────────────────────
@@ -148,9 +166,15 @@ type browseObj struct {
 	title      string
 	dispWidth  int
 	dispHeight int
+	termWidth  int
+	termHeight int
 	dispRows   int
+	paneTop    int
+	paneLeft   int
 	firstRow   int
 	lastRow    int
+	firstSeg   int
+	lastSeg    int
 
 	// File handling and structure
 	fp          *os.File
@@ -160,21 +184,42 @@ type browseObj struct {
 	fileName    string
 	absFileName string
 	fromStdin   bool
+	encoding    int
+	encodingOpt int
 	currentList []string
+	listLen     int
+	listDepth   int
 	mapSiz      int
 	seekMap     []int64
 	sizeMap     []int64
+	fileSeekMap []int64
+	fileSizeMap []int64
+	mapSeq      uint64
+	lineMap     []int
+	scanLines   int
+	filter      *lineFilter
+	filtering   *filterJob
 	shiftWidth  int
 	lastKey     byte
+	dirList     *dirListing
+	dirCursor   int
 
 	// Search and match
 	pattern      string
 	re           *regexp.Regexp
-	replace      string
+	field        *fieldSearch
+	pinned       []pinnedPattern
 	ignoreCase   bool
 	searchFixed  bool
+	smartCase    bool
+	wholeWord    bool
 	lastMatch    int
+	search       *searchJob
+	count        *matchCount
+	searchList   bool
+	handoff      *searchHandoff
 	matchScratch []byte
+	hexNeedle    []byte
 
 	// State flags
 	hitEOF      bool
@@ -198,12 +243,33 @@ type browseObj struct {
 	// Display settings
 	modeNumbers bool
 	modeScroll  int
+	modeWrap    bool
+	wraps       wrapCache
+	modeHex     bool
+	ctrlMode    int
+	modeSyntax  bool
+	syntax      *syntaxLang
+	logView     bool
+	logStyle    int
+	modeTable   bool
+	table       *tableView
+	tableRow    tableRow
+
+	// Status line
+	modeStatus   bool
+	statusFields []int
+	statusShown  string
+
+	// Split screen
+	split       *splitView
+	paintedSize int
 
 	// Synchronization
 	mutex         sync.Mutex
 	rereadPending bool
 	rereadReady   bool
-	stdinEOF      bool
+	stdin         *stdinStream
+	readerWake    chan struct{}
 }
 
 // browseResumeState preserves the visible position when a nested list returns.
@@ -215,6 +281,10 @@ type browseResumeState struct {
 	firstRow    int
 	lastRow     int
 	shiftWidth  int
+	dirCursor   int
+	filter      *lineFilter
+	table       *tableView
+	modeTable   bool
 }
 
 // vim: set ts=4 sw=4 noet:
@@ -0,0 +1,327 @@
+// filter.go
+// show only the lines that match, or do not match, a pattern
+//
+// Copyright (c) 2024-2026 jjb
+// All rights reserved.
+//
+// This source code is licensed under the MIT license found
+// in the root directory of this source tree.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// lineFilter selects the lines the reader maps. While a filter is set,
+// line n of the view is line lineMap[n] of the file.
+type lineFilter struct {
+	pattern string
+	re      *regexp.Regexp
+	exclude bool
+}
+
+// keeps reports whether the filter shows a line.
+func (f *lineFilter) keeps(line []byte) bool {
+	return f.re.Match(line) != f.exclude
+}
+
+// origLine returns the file line number of a view line.
+func (br *browseObj) origLine(lineno int) int {
+	br.mutex.Lock()
+	defer br.mutex.Unlock()
+
+	if br.lineMap == nil || lineno <= 0 {
+		return lineno
+	}
+
+	if lineno < len(br.lineMap) {
+		return br.lineMap[lineno]
+	}
+
+	// EOF
+	return br.scanLines + 1
+}
+
+// viewLine returns the view line showing a file line, or the first one
+// after it when the filter hides it.
+func (br *browseObj) viewLine(lineno int) int {
+	br.mutex.Lock()
+	defer br.mutex.Unlock()
+
+	if br.lineMap == nil {
+		return lineno
+	}
+
+	return sort.SearchInts(br.lineMap, lineno)
+}
+
+// filterCommand prompts for a filter. A leading ! shows the lines that do
+// not match, & stands for the search pattern, and an empty answer clears
+// the filter.
+func (br *browseObj) filterCommand() {
+	if br.modeHex {
+		br.printMessage("Filters apply to text lines", MSG_ORANGE)
+		return
+	}
+
+	lbuf, cancelled := br.userInput("Filter: ")
+	if cancelled {
+		return
+	}
+
+	if strings.TrimSpace(lbuf) == "" {
+		if br.filter == nil {
+			br.printMessage("No filter", MSG_ORANGE)
+		} else {
+			br.setFilter(nil)
+		}
+		return
+	}
+
+	f := &lineFilter{}
+	f.pattern, f.exclude = strings.CutPrefix(lbuf, "!")
+	if br.pattern != "" {
+		f.pattern = subCommandChars(f.pattern, "&", br.pattern)
+	}
+
+	if f.pattern == "" {
+		br.printMessage("Empty filter pattern", MSG_ORANGE)
+		return
+	}
+
+	re, err := br.compileSearch(f.pattern)
+	if err != nil {
+		br.printMessage(fmt.Sprintf("Regex compilation error: %v", err), MSG_ORANGE)
+		return
+	}
+	f.re = re
+
+	br.setFilter(f)
+}
+
+// filterJob maps the lines read so far through a new filter, or none, in
+// its own goroutine. It reads each line at the offset the reader found,
+// so the file is not scanned for newlines again. The main loop polls it
+// between keystrokes, as it does a search.
+type filterJob struct {
+	filter   *lineFilter
+	mapSeq   uint64
+	fp       *os.File
+	encoding int
+	ctrlMode int
+	total    int
+	scanned  atomic.Int64
+	cancel   atomic.Bool
+	done     chan struct{}
+
+	// maps of file lines 1 to lines; err is set before done is closed
+	lines   int
+	seekMap []int64
+	sizeMap []int64
+	lineMap []int
+	err     error
+}
+
+// fileMaps returns the offsets and sizes of every line read, kept by the
+// filter or not. br.mutex must be held.
+func (br *browseObj) fileMaps() ([]int64, []int64) {
+	if br.fileSeekMap != nil {
+		return br.fileSeekMap, br.fileSizeMap
+	}
+
+	return br.seekMap[:br.mapSiz], br.sizeMap[:br.mapSiz]
+}
+
+// setFilter maps the file again through a new filter, or none, keeping
+// the top line of the page in view. A short job finishes at once; a long
+// one shows its progress until the main loop sees it finish.
+func (br *browseObj) setFilter(f *lineFilter) {
+	br.mutex.Lock()
+	seekMap, sizeMap := br.fileMaps()
+	job := &filterJob{
+		filter:   f,
+		mapSeq:   br.mapSeq,
+		fp:       br.fp,
+		encoding: br.encoding,
+		ctrlMode: br.ctrlMode,
+		total:    len(seekMap),
+		done:     make(chan struct{}),
+		seekMap:  []int64{0},
+		sizeMap:  []int64{0},
+	}
+	br.mutex.Unlock()
+
+	if f != nil {
+		job.lineMap = []int{0}
+	}
+
+	go func() {
+		job.err = job.mapLines(seekMap, sizeMap)
+		close(job.done)
+	}()
+
+	select {
+	case <-job.done:
+		br.finishFilter(job)
+
+	case <-time.After(SEARCH_WAIT):
+		br.filtering = job
+		br.filterProgress(job)
+	}
+}
+
+// mapLines adds the lines of seekMap and sizeMap past those mapped so far
+// to the job's maps. It stops early when the job is cancelled.
+func (job *filterJob) mapLines(seekMap, sizeMap []int64) error {
+	if job.filter == nil {
+		job.lines = len(seekMap) - 1
+		return nil
+	}
+
+	var buf []byte
+	for lineno := job.lines + 1; lineno < len(seekMap); lineno++ {
+		if job.cancel.Load() {
+			return nil
+		}
+
+		if size := int(sizeMap[lineno]); cap(buf) < size {
+			buf = make([]byte, size)
+		}
+		buf = buf[:sizeMap[lineno]]
+
+		n, err := job.fp.ReadAt(buf, seekMap[lineno])
+		if err != nil && err != io.EOF {
+			return err
+		}
+
+		// filters see the line as it is displayed
+		if job.filter.keeps(visibleLine(buf[:n], seekMap[lineno], job.encoding, job.ctrlMode)) {
+			job.seekMap = append(job.seekMap, seekMap[lineno])
+			job.sizeMap = append(job.sizeMap, sizeMap[lineno])
+			job.lineMap = append(job.lineMap, lineno)
+		}
+
+		job.lines = lineno
+		job.scanned.Store(int64(lineno))
+	}
+
+	return nil
+}
+
+// finishFilter maps any lines read since the job started and puts the
+// job's maps in place.
+func (br *browseObj) finishFilter(job *filterJob) {
+	offsets := br.viewOffsets()
+
+	for {
+		br.mutex.Lock()
+		changed := br.mapSeq != job.mapSeq
+		seekMap, sizeMap := br.fileMaps()
+		if !changed && job.err == nil && len(seekMap) == job.lines+1 {
+			br.installFilter(job, seekMap, sizeMap)
+			br.mutex.Unlock()
+			break
+		}
+		br.mutex.Unlock()
+
+		// a re-read file has new maps, and may have closed the job's file
+		if changed {
+			br.printMessage("File changed while filtering", MSG_ORANGE)
+			return
+		}
+
+		if job.err == nil {
+			job.err = job.mapLines(seekMap, sizeMap)
+		}
+
+		if job.err != nil {
+			br.printMessage(fmt.Sprintf("Cannot filter: %v", job.err), MSG_RED)
+			return
+		}
+	}
+
+	br.restoreViewOffsets(offsets)
+	br.pageCurrent()
+
+	f := job.filter
+	if f == nil {
+		br.printMessage("Filter cleared", MSG_GREEN)
+	} else if br.currentMapSize() <= 1 {
+		br.printMessage("No lines pass the filter", MSG_ORANGE)
+	} else if f.exclude {
+		br.printMessage("Hiding "+f.pattern, MSG_GREEN)
+	} else {
+		br.printMessage("Showing "+f.pattern, MSG_GREEN)
+	}
+}
+
+// installFilter replaces the line maps with a finished job's. The reader
+// goes on from them with the new filter. br.mutex must be held.
+func (br *browseObj) installFilter(job *filterJob, seekMap, sizeMap []int64) {
+	br.filter = job.filter
+
+	if job.filter == nil {
+		br.seekMap, br.sizeMap, br.lineMap = seekMap, sizeMap, nil
+		br.fileSeekMap, br.fileSizeMap = nil, nil
+	} else {
+		br.seekMap, br.sizeMap, br.lineMap = job.seekMap, job.sizeMap, job.lineMap
+		br.fileSeekMap, br.fileSizeMap = seekMap, sizeMap
+	}
+
+	br.mapSiz = len(br.seekMap)
+	br.scanLines = job.lines
+	br.hitEOF = false
+	br.mapSeq++
+}
+
+// pollFilter puts the running filter in place once it finishes, or shows
+// its progress until then.
+func (br *browseObj) pollFilter() {
+	job := br.filtering
+
+	select {
+	case <-job.done:
+		br.filtering = nil
+		br.finishFilter(job)
+
+	default:
+		br.filterProgress(job)
+	}
+}
+
+// filterProgress shows how much of the file the filter has mapped.
+func (br *browseObj) filterProgress(job *filterJob) {
+	percent := 0
+	if job.total > 0 {
+		percent = int(min(job.scanned.Load()*100/int64(job.total), 99))
+	}
+
+	br.printMessage(fmt.Sprintf("Filtering %d%% ... Ctrl+C cancels", percent), MSG_GREEN)
+}
+
+// filterKey handles a key pressed while a filter is mapped. Keys other
+// than the cancel keys are ignored until it finishes, when the view is
+// left as it was.
+func (br *browseObj) filterKey(key []byte) {
+	if len(key) != 1 || (key[0] != SEARCH_CANCEL && key[0] != SEARCH_CANCEL_1) {
+		br.pollFilter()
+		return
+	}
+
+	job := br.filtering
+	job.cancel.Store(true)
+	<-job.done
+	br.filtering = nil
+
+	br.printMessage("Filter cancelled", MSG_ORANGE)
+}
+
+// vim: set ts=4 sw=4 noet:
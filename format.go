@@ -60,6 +60,9 @@ func (br *browseObj) runFormat() {
 
 	if br.pattern != "" {
 		cmd += " -p " + shellEscapeSingle(br.pattern)
+		if flags := br.searchFlags(); flags != "" {
+			cmd += " " + flags
+		}
 	}
 
 	// Display command preview
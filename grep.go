@@ -1,5 +1,5 @@
 // grep.go
-// pipe the current search to grep -nP
+// list the lines matching the search pattern
 //
 // Copyright (c) 2024-2026 jjb
 // All rights reserved.
@@ -10,78 +10,167 @@
 package main
 
 import (
+	"bufio"
 	"fmt"
+	"io"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"regexp"
+	"time"
 )
 
-// grep options for the external grep command and browse flags.
-const (
-	grepDefaultOpts  = "-nP"
-	grepIgnoreCase   = "-inP"
-	browseIgnoreCase = "-i"
-)
+// grepResults is where a grep job writes the lines it finds. They go to
+// the spool as they are found, so a long list is not held in memory.
+type grepResults struct {
+	re      *regexp.Regexp
+	title   string
+	out     *io.PipeWriter
+	sp      *spoolObj
+	listing *dirListing
+}
 
-// runGrep pipes the current search pattern to grep and opens the results.
-func (br *browseObj) runGrep() {
-	if br.pattern == "" {
+// runGrep lists the lines matching the search pattern, grep -n style, in
+// a nested view. Enter on a result returns to the file at its line. The
+// lines are found by a background search, which shows its progress and
+// can be cancelled when it runs long. It returns true when the file must
+// be reopened.
+func (br *browseObj) runGrep() bool {
+	if br.re == nil {
 		br.printMessage("No search pattern", MSG_ORANGE)
-		return
+		return false
 	}
 
-	// Check if file exists before attempting to grep
-	_, err := os.Stat(br.fileName)
-	if err != nil {
-		br.printMessage("File does not exist", MSG_ORANGE)
-		return
+	if br.modeHex {
+		br.printMessage("Grep applies to text lines", MSG_ORANGE)
+		return false
 	}
 
-	grepPath, err := exec.LookPath("grep")
-	if err != nil {
-		br.printMessage("Cannot find 'grep' in $PATH", MSG_ORANGE)
-		return
+	title := fmt.Sprintf("grep \"%s\"", br.pattern)
+	if !br.fromStdin {
+		title += " " + filepath.Base(br.sourceName())
 	}
 
-	brPath, err := os.Executable()
+	pr, pw := io.Pipe()
+	sp, err := newSpool(pr, title, 0)
 	if err != nil {
-		br.printMessage("Cannot find 'browse' executable", MSG_ORANGE)
-		return
+		br.printMessage(err.Error(), MSG_RED)
+		return false
+	}
+
+	grep := &grepResults{re: br.re, title: title, out: pw, sp: sp, listing: &dirListing{}}
+	sp.listing = grep.listing
+
+	mapSize := br.searchMapSize()
+	job := &searchJob{forward: true, total: mapSize, done: make(chan struct{}), grep: grep}
+
+	go func() {
+		br.grepLines(job, mapSize)
+		close(job.done)
+	}()
+
+	// as runSearch does, short scans show no progress
+	select {
+	case <-job.done:
+		return br.showGrepResults(job)
+
+	case <-time.After(SEARCH_WAIT):
+		br.search = job
+		br.searchProgress(job)
+		return false
 	}
+}
 
-	// case sensitivity
-	grepOpts := grepDefaultOpts
-	brOpts := ""
-	if br.ignoreCase {
-		grepOpts = grepIgnoreCase
-		brOpts = browseIgnoreCase
+// grepLines writes the matching lines among the first mapSize to the
+// job's spool, until the job is cancelled.
+func (br *browseObj) grepLines(job *searchJob, mapSize int) {
+	grep := job.grep
+	w := bufio.NewWriterSize(grep.out, 64*1024)
+
+	// results keep view line numbers, which a filter maps again on return
+	for lineno := 1; lineno < mapSize && !job.cancel.Load(); lineno++ {
+		job.scanned.Add(1)
+		if br.lineMatches(grep.re, lineno, &job.scratch) {
+			grep.listing.lines = append(grep.listing.lines, lineno)
+			fmt.Fprintf(w, "%d:%s\n", br.origLine(lineno), br.readFromMap(lineno))
+		}
 	}
 
-	title := fmt.Sprintf("grep %s -e \"%s\"", grepOpts, br.pattern)
-	if !br.fromStdin {
-		title += " " + filepath.Base(br.fileName)
+	w.Flush()
+	grep.out.Close()
+}
+
+// showGrepResults browses the lines a finished grep job found. It returns
+// true when the file must be reopened.
+func (br *browseObj) showGrepResults(job *searchJob) bool {
+	grep := job.grep
+	defer grep.sp.close()
+
+	listing := grep.listing
+	if len(listing.lines) == 0 {
+		br.printMessage("Pattern not found", MSG_ORANGE)
+		moveCursor(2, 1, false)
+		return false
+	}
+
+	sp, title := grep.sp, grep.title
+
+	resume := br.saveResumeState()
+	br.browseResults(sp, title)
+
+	if br.listAction == LIST_ACTION_EXIT_ALL {
+		return true
+	}
+
+	br.resume = resume
+	if listing.selected > 0 {
+		br.resume.firstRow = listing.selected
+		br.resume.lastRow = listing.selected
+		br.resume.dirCursor = listing.selected
+	}
+
+	restoreResumeState(br)
+	br.saveRC = false
+	br.listAction = LIST_ACTION_RESUME
+	return true
+}
+
+// browseResults browses a generated listing as a list of its own, coming
+// back to it from lists opened inside it.
+func (br *browseObj) browseResults(sp *spoolObj, title string) {
+	savedList := br.currentList
+	savedListLen := br.listLen
+	savedListAtStart := br.listAtStart
+	br.listDepth++
+	defer func() {
+		br.currentList = savedList
+		br.listLen = savedListLen
+		br.listAtStart = savedListAtStart
+		br.listDepth--
+		br.exit = false
+	}()
+
+	br.currentList = []string{sp.name}
+	br.listLen = 1
+	br.listAtStart = true
+	resetState(br)
+
+	for {
+		fp, err := os.Open(sp.name)
+		if err != nil {
+			return
+		}
+
+		br.absFileName = sp.name
+		browseFile(br, fp, sp.name, title, false)
+		fp.Close()
+
+		if br.listAction != LIST_ACTION_RESUME {
+			return
+		}
+
+		br.listAction = LIST_ACTION_NONE
+		restoreResumeState(br)
 	}
-	patternArg := shellEscapeSingle(br.pattern)
-	titleArg := shellEscapeSingle(title)
-	fileNameArg := shellEscapeSingle(br.fileName)
-	grepPathArg := shellEscapeSingle(grepPath)
-	brPathArg := shellEscapeSingle(brPath)
-
-	// Construct command - grep should pipe output to browse for display
-	cmd := fmt.Sprintf(
-		"%s %s -e %s %s | %s %s -p %s -t %s",
-		grepPathArg, grepOpts, patternArg, fileNameArg,
-		brPathArg, brOpts, patternArg, titleArg,
-	)
-
-	// Display command preview
-	moveCursor(br.dispHeight, 1, true)
-	fmt.Printf("---\n%s%s%s\n", LINEWRAPON, shellPrompt(), cmd)
-
-	// Run command in a PTY
-	resetScrRegion()
-	br.runInPty(cmd)
-	br.resizeWindow()
 }
 
 // vim: set ts=4 sw=4 noet:
@@ -33,7 +33,9 @@ func (br *browseObj) printHelp() {
 		"  < [Backspace] [Ctrl+Left]         Scroll 4 characters left               ",
 		"  ^ $                               Scroll to column 1, scroll to EOL      ",
 		"  e [End]  t                        Follow/Tail mode                       ",
-		"  #                                 Line numbers                           ",
+		"  # T                               Line numbers/Status line               ",
+		"  w D V y l                         Wrap/Hex/Controls/Syntax/Log colors    ",
+		"  | k E                             Columns/Choose columns/Expand record   ",
 		"  % = Ctrl+G                        File position                          ",
 		"  j 1-9                             Jump to line/Jump to mark              ",
 		"  0 [Home]                          Jump to SOF, column 1                  ",
@@ -41,16 +43,17 @@ func (br *browseObj) printHelp() {
 		"  m                                 Mark a page with number 1-9            ",
 		"  / ?                               Regex search forward/reverse           ",
 		"  n N                               Repeat search forward/reverse          ",
-		"  i I                               Case-sensitive/Fixed-string search     ",
+		"  i I s W A                         Case/Fixed/Smart-case/Word/List search ",
 		"  F                                 Run 'fmt -s' on the current file       ",
-		"  &                                 Run 'grep -nP' for pattern             ",
-		"  p P                               Print/Clear search pattern             ",
+		"  & L                               List matches/Filter lines, !excludes   ",
+		"  p P * U                           Print/Clear/Pin pattern, Unpin all     ",
 		"  !                                 bash command                           ",
 		"  B                                 Browse file (expands %, ~, glob)       ",
 		"  R                                 Re-read current file                   ",
 		"  Ctrl+R                            Rewind current browse list             ",
 		"  a                                 Print filenames in the browse list     ",
 		"  c C                               Print/Change working directory         ",
+		"  o O Ctrl+W [ ] K                  Split/Switch/Resize/Lock panes         ",
 		"  h H                               Show help screen/man page              ",
 		"  q Q                               Quit, save/don't save browserc         ",
 		"  x X                               Exit list, save/don't save browserc    ",
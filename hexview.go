@@ -0,0 +1,388 @@
+// hexview.go
+// hex and ASCII dump of binary files
+//
+// Copyright (c) 2024-2026 jjb
+// All rights reserved.
+//
+// This source code is licensed under the MIT license found
+// in the root directory of this source tree.
+
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Hex view layout.
+const (
+	// bytes shown on each row
+	HEXWIDTH = 16
+
+	// bytes read either side of a row so matches can cross rows
+	HEX_CONTEXT = 256
+
+	// records a search reads at a time
+	HEX_SCAN_RECORDS = 16384
+
+	// prefix of a search for a byte sequence
+	HEX_MARKER = "hex:"
+)
+
+// In the hex view line n is the record of HEXWIDTH bytes at offset
+// (n-1)*HEXWIDTH. The records are computed from the file size, so the
+// reader keeps no seek or size maps for them.
+
+// hexRecordOffset returns the file offset of a hex record.
+func hexRecordOffset(lineno int) int64 {
+	return int64(max(lineno-1, 0)) * HEXWIDTH
+}
+
+// hexOffsetRecord returns the hex record holding a file offset.
+func hexOffsetRecord(offset int64) int {
+	return int(offset/HEXWIDTH) + 1
+}
+
+// publishHexRecords sizes the hex view to the file. It returns false if
+// the file changed underneath the reader.
+func (br *browseObj) publishHexRecords(fileSeq uint64) bool {
+	br.mutex.Lock()
+	defer br.mutex.Unlock()
+
+	if br.fileSeq != fileSeq {
+		return false
+	}
+
+	// a partial last record is read as it grows
+	records := 1 + int((br.newFileSiz+HEXWIDTH-1)/HEXWIDTH)
+	if records > br.mapSiz {
+		br.hitEOF = false
+	}
+
+	br.mapSiz = records
+	br.savFileSiz = br.newFileSiz
+	br.savInode = br.newInode
+
+	return true
+}
+
+// parseHexPattern returns the bytes of a hex search pattern, such as
+// hex:7f454c46 or "hex: 7f 45 4c 46", or nil for other patterns. The
+// marker keeps text such as 0xdeadbeef searchable.
+func parseHexPattern(pattern string) []byte {
+	digits, ok := strings.CutPrefix(strings.ToLower(pattern), HEX_MARKER)
+	if !ok {
+		return nil
+	}
+
+	needle, err := hex.DecodeString(strings.ReplaceAll(digits, " ", ""))
+	if err != nil || len(needle) == 0 {
+		return nil
+	}
+
+	return needle
+}
+
+// hexWindow reads a record with HEX_CONTEXT bytes either side. It returns
+// the window, the record's bounds within it, and the record's offset.
+func (br *browseObj) hexWindow(lineno int) ([]byte, int, int, int64) {
+	br.mutex.Lock()
+	if lineno <= 0 || lineno >= br.mapSiz || br.fp == nil {
+		br.mutex.Unlock()
+		return nil, 0, 0, 0
+	}
+
+	offset := hexRecordOffset(lineno)
+	start := max(offset-HEX_CONTEXT, 0)
+	window := make([]byte, offset+HEXWIDTH+HEX_CONTEXT-start)
+
+	n, err := br.fp.ReadAt(window, start)
+	br.mutex.Unlock()
+	if err != nil && err != io.EOF {
+		return nil, 0, 0, 0
+	}
+
+	recStart := int(offset - start)
+	if recStart >= n {
+		return nil, 0, 0, 0
+	}
+
+	return window[:n], recStart, min(recStart+HEXWIDTH, n), offset
+}
+
+// hexMatches returns the byte ranges in data that match the search. A
+// hex: pattern matches its bytes; anything else is a regex over the text.
+func (br *browseObj) hexMatches(data []byte) [][]int {
+	if br.hexNeedle == nil {
+		if br.re == nil {
+			return nil
+		}
+
+		return br.re.FindAllIndex(data, -1)
+	}
+
+	var matches [][]int
+
+	for pos := 0; ; {
+		i := bytes.Index(data[pos:], br.hexNeedle)
+		if i < 0 {
+			return matches
+		}
+
+		start := pos + i
+		matches = append(matches, []int{start, start + len(br.hexNeedle)})
+		pos = start + 1
+	}
+}
+
+// hexIsMatch reports whether a match starts in a hex record, so a match
+// that crosses rows is found once.
+func (br *browseObj) hexIsMatch(lineno int) bool {
+	if br.re == nil {
+		return false
+	}
+
+	data, recStart, recEnd, _ := br.hexWindow(lineno)
+
+	for _, m := range br.hexMatches(data) {
+		if m[0] >= recStart && m[0] < recEnd {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hexScanMatches reads the records from first up to last in one piece,
+// with HEX_CONTEXT bytes either side, and returns the records in which a
+// match starts, in order.
+func (br *browseObj) hexScanMatches(first, last int) []int {
+	br.mutex.Lock()
+	first, last = max(first, 1), min(last, br.mapSiz)
+	if first >= last || br.fp == nil {
+		br.mutex.Unlock()
+		return nil
+	}
+
+	offset, end := hexRecordOffset(first), hexRecordOffset(last)
+	start := max(offset-HEX_CONTEXT, 0)
+	data := make([]byte, end+HEX_CONTEXT-start)
+
+	n, err := br.fp.ReadAt(data, start)
+	br.mutex.Unlock()
+	if err != nil && err != io.EOF {
+		return nil
+	}
+
+	var records []int
+	for _, m := range br.hexMatches(data[:n]) {
+		at := start + int64(m[0])
+		if at < offset || at >= end {
+			continue
+		}
+
+		if rec := hexOffsetRecord(at); len(records) == 0 || records[len(records)-1] != rec {
+			records = append(records, rec)
+		}
+	}
+
+	return records
+}
+
+// hexFindForward returns the first record from startLine up to endLine
+// in which a match starts, or -1. It reads HEX_SCAN_RECORDS at a time
+// rather than a window for each record.
+func (br *browseObj) hexFindForward(job *searchJob, startLine, endLine int) int {
+	for first := startLine; first < endLine && !job.cancel.Load(); first += HEX_SCAN_RECORDS {
+		last := min(first+HEX_SCAN_RECORDS, endLine)
+		job.scanned.Add(int64(last - first))
+
+		if records := br.hexScanMatches(first, last); len(records) > 0 {
+			return records[0]
+		}
+	}
+
+	return -1
+}
+
+// hexFindReverse returns the last record from startLine down to endLine
+// in which a match starts, or -1.
+func (br *browseObj) hexFindReverse(job *searchJob, startLine, endLine int) int {
+	for last := startLine + 1; last > endLine && !job.cancel.Load(); last -= HEX_SCAN_RECORDS {
+		first := max(last-HEX_SCAN_RECORDS, endLine)
+		job.scanned.Add(int64(last - first))
+
+		if records := br.hexScanMatches(first, last); len(records) > 0 {
+			return records[len(records)-1]
+		}
+	}
+
+	return -1
+}
+
+// hexRow formats a record as offset, hex bytes and printable ASCII, with
+// matched bytes highlighted in both columns.
+func (br *browseObj) hexRow(lineno int) string {
+	data, recStart, recEnd, offset := br.hexWindow(lineno)
+	if data == nil {
+		return ""
+	}
+
+	record := data[recStart:recEnd]
+	matched := make([]bool, len(record))
+
+	if br.re != nil {
+		for _, m := range br.hexMatches(data) {
+			for i := max(m[0], recStart); i < min(m[1], recEnd); i++ {
+				matched[i-recStart] = true
+			}
+		}
+	}
+
+	var sb strings.Builder
+	sb.Grow(HEXWIDTH*8 + 32)
+	fmt.Fprintf(&sb, "%s%08x%s  ", _VID_DIM, offset, VIDOFF)
+
+	// highlight runs of matched bytes
+	lit := false
+	setLit := func(on bool) {
+		if on != lit {
+			if on {
+				sb.WriteString(MSG_GREEN)
+			} else {
+				sb.WriteString(VIDOFF)
+			}
+			lit = on
+		}
+	}
+
+	for i := range HEXWIDTH {
+		if i == HEXWIDTH/2 {
+			sb.WriteByte(' ')
+		}
+
+		if i >= len(record) {
+			setLit(false)
+			sb.WriteString("   ")
+			continue
+		}
+
+		setLit(matched[i])
+		fmt.Fprintf(&sb, "%02x", record[i])
+		setLit(matched[i] && i+1 < len(record) && matched[i+1] && i+1 != HEXWIDTH/2)
+		sb.WriteByte(' ')
+	}
+
+	setLit(false)
+	sb.WriteString(" |")
+
+	for i, c := range record {
+		if c < ' ' || c > '~' {
+			c = '.'
+		}
+
+		setLit(matched[i])
+		sb.WriteByte(c)
+	}
+
+	setLit(false)
+	sb.WriteByte('|')
+
+	return sb.String()
+}
+
+// rowOffset returns the file offset where a line or hex record starts.
+func (br *browseObj) rowOffset(row int) int64 {
+	br.mutex.Lock()
+	defer br.mutex.Unlock()
+
+	row = min(row, br.mapSiz-1)
+	if row <= 0 {
+		return 0
+	}
+
+	if br.modeHex {
+		return hexRecordOffset(row)
+	}
+
+	return br.seekMap[row]
+}
+
+// offsetRow returns the line or hex record holding a file offset.
+func (br *browseObj) offsetRow(offset int64) int {
+	br.mutex.Lock()
+	defer br.mutex.Unlock()
+
+	if br.mapSiz <= 1 {
+		return 0
+	}
+
+	if br.modeHex {
+		return min(hexOffsetRecord(offset), br.mapSiz-1)
+	}
+
+	// first line starting past offset, less one
+	row := sort.Search(br.mapSiz-1, func(i int) bool {
+		return br.seekMap[i+1] > offset
+	})
+
+	return max(row, 1)
+}
+
+// toggleHex switches between the hex and text views. Filters apply to
+// text lines only, so entering the hex view clears any filter.
+func (br *browseObj) toggleHex() {
+	if !br.remapFile(func() {
+		br.modeHex = !br.modeHex
+		br.filter = nil
+	}) {
+		return
+	}
+
+	br.shiftWidth = 0
+
+	if br.modeTable {
+		// hex rows have no columns
+		br.modeTable = false
+		br.setScreenSize(br.termWidth, br.termHeight)
+		br.pageHeader()
+	}
+
+	br.pageCurrent()
+
+	if br.modeHex {
+		br.printMessage("Hex view", MSG_GREEN)
+	} else {
+		br.printMessage("Text view", MSG_GREEN)
+	}
+}
+
+// jumpOffset prompts for a byte offset, decimal or 0x hex, and shows
+// the hex record holding it.
+func (br *browseObj) jumpOffset() {
+	lbuf, cancelled := br.userInput("Offset: ")
+	if cancelled || len(lbuf) == 0 {
+		return
+	}
+
+	input := strings.ToLower(strings.TrimSpace(lbuf))
+	base := 10
+	if digits, ok := strings.CutPrefix(input, "0x"); ok {
+		input, base = digits, 16
+	}
+
+	offset, err := strconv.ParseInt(input, base, 64)
+	if err != nil || offset < 0 {
+		br.printMessage("Invalid offset", MSG_ORANGE)
+		return
+	}
+
+	br.printPage(hexOffsetRecord(offset))
+}
+
+// vim: set ts=4 sw=4 noet:
@@ -0,0 +1,305 @@
+// highlight.go
+// pinned highlight patterns and match coloring
+//
+// Copyright (c) 2024-2026 jjb
+// All rights reserved.
+//
+// This source code is licensed under the MIT license found
+// in the root directory of this source tree.
+
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Byte paint values returned by matchPaint.
+const (
+	PAINT_NONE   = 0
+	PAINT_SEARCH = 1
+	PAINT_PIN    = 2
+)
+
+// pinColors are the colors given to pinned patterns, in the order they
+// are handed out. The names are saved in the rcfile.
+var pinColors = []struct {
+	name string
+	attr string
+}{
+	{"yellow", _VID_BLACK_FG + _VID_YELLOW_BG},
+	{"cyan", _VID_BLACK_FG + _VID_CYAN_BG},
+	{"magenta", _VID_BLACK_FG + _VID_MAGENTA_BG},
+	{"violet", _VID_BLACK_FG + _VID_VIOLET_BG},
+	{"pink", _VID_BLACK_FG + _VID_PINK_BG},
+	{"sky", _VID_BLACK_FG + _VID_SKY_BG},
+}
+
+// pinnedPattern is a pattern that stays highlighted while other patterns
+// are searched. It keeps the case, fixed-string and whole-word flags it was
+// pinned with.
+type pinnedPattern struct {
+	pattern    string
+	ignoreCase bool
+	fixed      bool
+	word       bool
+	color      int
+	re         *regexp.Regexp
+}
+
+// compilePattern compiles a search pattern with the given flags.
+func compilePattern(pattern string, ignoreCase, fixed, word bool) (*regexp.Regexp, error) {
+	if fixed {
+		pattern = regexp.QuoteMeta(pattern)
+	}
+
+	if word {
+		pattern = `\b(?:` + pattern + `)\b`
+	}
+
+	if ignoreCase {
+		pattern = "(?i)" + pattern
+	}
+
+	return regexp.Compile(pattern)
+}
+
+// pinIndex returns the pin matching the active pattern and flags, or -1.
+func (br *browseObj) pinIndex() int {
+	return slices.IndexFunc(br.pinned, func(p pinnedPattern) bool {
+		return p.pattern == br.pattern && p.ignoreCase == br.caseless(br.pattern) &&
+			p.fixed == br.searchFixed && p.word == br.wholeWord
+	})
+}
+
+// togglePin pins the active pattern, or unpins it if already pinned.
+func (br *browseObj) togglePin() {
+	if br.re == nil {
+		br.printMessage("No search pattern", MSG_ORANGE)
+		return
+	}
+
+	if i := br.pinIndex(); i >= 0 {
+		br.pinned = slices.Delete(br.pinned, i, i+1)
+		br.pageCurrent()
+		br.printMessage("Unpinned "+br.pattern, MSG_GREEN)
+		return
+	}
+
+	color := br.freePinColor()
+	if color < 0 {
+		br.printMessage("All pin colors are in use", MSG_ORANGE)
+		return
+	}
+
+	br.pinned = append(br.pinned, pinnedPattern{
+		pattern:    br.pattern,
+		ignoreCase: br.caseless(br.pattern),
+		fixed:      br.searchFixed,
+		word:       br.wholeWord,
+		color:      color,
+		re:         br.re,
+	})
+
+	br.pageCurrent()
+	br.printMessage("Pinned "+br.pattern, pinColors[color].attr)
+}
+
+// clearPins unpins every pattern.
+func (br *browseObj) clearPins() {
+	if len(br.pinned) == 0 {
+		br.printMessage("No pinned patterns", MSG_ORANGE)
+		return
+	}
+
+	br.pinned = nil
+	br.pageCurrent()
+	br.printMessage("Pinned patterns cleared", MSG_GREEN)
+}
+
+// freePinColor returns the first color no pin is using, or -1.
+func (br *browseObj) freePinColor() int {
+	for color := range pinColors {
+		if !slices.ContainsFunc(br.pinned, func(p pinnedPattern) bool {
+			return p.color == color
+		}) {
+			return color
+		}
+	}
+
+	return -1
+}
+
+// formatPin formats a pin for the rcfile as color, ignoreCase, fixed, word
+// and pattern, separated by spaces.
+func formatPin(p pinnedPattern) string {
+	return fmt.Sprintf("%s %t %t %t %s", pinColors[p.color].name, p.ignoreCase, p.fixed, p.word, p.pattern)
+}
+
+// parsePin adds a pin saved by formatPin. Pins saved before the word flag
+// have no word field.
+func (br *browseObj) parsePin(line string) bool {
+	fields := strings.SplitN(line, " ", 4)
+	if len(fields) != 4 || len(br.pinned) >= len(pinColors) {
+		return false
+	}
+
+	word := false
+	if flag, pattern, ok := strings.Cut(fields[3], " "); ok && (flag == "true" || flag == "false") {
+		word = flag == "true"
+		fields[3] = pattern
+	}
+
+	color := slices.IndexFunc(pinColors, func(c struct{ name, attr string }) bool {
+		return c.name == fields[0]
+	})
+
+	ignoreCase, err1 := strconv.ParseBool(fields[1])
+	fixed, err2 := strconv.ParseBool(fields[2])
+	if color < 0 || err1 != nil || err2 != nil {
+		return false
+	}
+
+	re, err := compilePattern(fields[3], ignoreCase, fixed, word)
+	if err != nil {
+		return false
+	}
+
+	br.pinned = append(br.pinned, pinnedPattern{
+		pattern:    fields[3],
+		ignoreCase: ignoreCase,
+		fixed:      fixed,
+		word:       word,
+		color:      color,
+		re:         re,
+	})
+
+	return true
+}
+
+// matchPaint returns how each byte of a line is highlighted: PAINT_NONE,
+// PAINT_SEARCH for the active pattern, or PAINT_PIN+i for pin i. The
+// active pattern paints over pins unless it is pinned itself, in which case
+// it shows in its pin color. It returns nil when nothing matches.
+func (br *browseObj) matchPaint(lineno int, line []byte) []uint8 {
+	var paint []uint8
+
+	mark := func(matches [][]int, value uint8) {
+		for _, m := range matches {
+			// column matches may run past a line clipped for display
+			end := min(m[1], len(line))
+			if m[0] >= end {
+				continue
+			}
+
+			if paint == nil {
+				paint = make([]uint8, len(line))
+			}
+
+			for i := m[0]; i < end; i++ {
+				paint[i] = value
+			}
+		}
+	}
+
+	for i, p := range br.pinned {
+		matches, ok := [][]int(nil), false
+		if br.tabular() {
+			matches, ok = br.tableMatches(lineno, p.re, false)
+		}
+
+		if !ok {
+			matches = p.re.FindAllIndex(line, -1)
+		}
+
+		mark(matches, PAINT_PIN+uint8(i))
+	}
+
+	if br.re != nil && br.pinIndex() < 0 {
+		mark(br.searchMatches(lineno, line), PAINT_SEARCH)
+	}
+
+	return paint
+}
+
+// paintAttr returns the attributes for a paint value.
+func (br *browseObj) paintAttr(value uint8) string {
+	if value >= PAINT_PIN {
+		return pinColors[br.pinned[value-PAINT_PIN].color].attr
+	}
+
+	return MSG_GREEN
+}
+
+// paintSegment returns line[start:end] with painted bytes highlighted and
+// the rest of the text shown in the base attributes and the colors of its
+// spans. Highlights cover colors, which resume after them, and colors end
+// with the segment.
+func (br *browseObj) paintSegment(line []byte, start, end int, paint []uint8, base string, spans []sgrSpan) string {
+	state, next := sgrState(spans, start)
+
+	var sb strings.Builder
+	sb.Grow(end - start + len(base) + len(state))
+	sb.WriteString(base)
+	sb.WriteString(state)
+
+	if paint == nil && next == len(spans) {
+		sb.Write(line[start:end])
+		if state != "" {
+			sb.WriteString(VIDOFF + base)
+		}
+		return sb.String()
+	}
+
+	painted := uint8(PAINT_NONE)
+
+	for i := start; i < end; {
+		for ; next < len(spans) && spans[next].pos <= i; next++ {
+			sgr := spans[next].sgr
+			if isSGRReset(sgr) {
+				state = ""
+				sgr += base
+			} else {
+				state += sgr
+			}
+
+			if painted == PAINT_NONE {
+				sb.WriteString(sgr)
+			}
+		}
+
+		value := uint8(PAINT_NONE)
+		if paint != nil {
+			value = paint[i]
+		}
+
+		if value != painted {
+			if painted != PAINT_NONE {
+				sb.WriteString(VIDOFF + base + state)
+			}
+			if value != PAINT_NONE {
+				sb.WriteString(br.paintAttr(value))
+			}
+			painted = value
+		}
+
+		j := i + 1
+		for j < end && (paint == nil || paint[j] == value) && (next == len(spans) || spans[next].pos > j) {
+			j++
+		}
+
+		sb.Write(line[i:j])
+		i = j
+	}
+
+	if painted != PAINT_NONE || state != "" {
+		sb.WriteString(VIDOFF + base)
+	}
+
+	return sb.String()
+}
+
+// vim: set ts=4 sw=4 noet:
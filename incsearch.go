@@ -0,0 +1,152 @@
+// incsearch.go
+// move the view to matches while a search pattern is typed
+//
+// Copyright (c) 2024-2026 jjb
+// All rights reserved.
+//
+// This source code is licensed under the MIT license found
+// in the root directory of this source tree.
+
+package main
+
+import (
+	"regexp"
+	"time"
+
+	"github.com/mattn/go-runewidth"
+)
+
+// INCSEARCH_WAIT bounds the scan after each keystroke. A match further
+// away is found when Enter runs the full search.
+const INCSEARCH_WAIT = 100 * time.Millisecond
+
+// searchPreview holds the view a search prompt started from, so typing can
+// move away from it and Esc can bring it back.
+type searchPreview struct {
+	forward    bool
+	start      int
+	mapSize    int
+	firstRow   int
+	firstSeg   int
+	lastRow    int
+	shiftWidth int
+	re         *regexp.Regexp
+	field      *fieldSearch
+
+	text   string
+	drawn  bool
+	prefix int
+	cursor int
+}
+
+// newSearchPreview records the view before a search prompt. It returns nil
+// in hex mode, which searches bytes rather than patterns.
+func (br *browseObj) newSearchPreview(forward bool, prefix string) *searchPreview {
+	if br.modeHex {
+		return nil
+	}
+
+	mapSize := br.searchMapSize()
+
+	return &searchPreview{
+		forward:    forward,
+		start:      br.currentPageSearchStart(forward, mapSize),
+		mapSize:    mapSize,
+		firstRow:   br.firstRow,
+		firstSeg:   br.firstSeg,
+		lastRow:    br.lastRow,
+		shiftWidth: br.shiftWidth,
+		re:         br.re,
+		field:      br.field,
+		prefix:     runewidth.StringWidth(prefix),
+		cursor:     runewidth.StringWidth(prefix),
+	}
+}
+
+// previewSearch shows the first match of a partly typed pattern, with the
+// pattern highlighted. Patterns that do not compile yet are ignored, and
+// the original view returns when nothing matches.
+func (br *browseObj) previewSearch(p *searchPreview, text, beforeCursor string) {
+	// the cursor is still where the prompt left it for the previous text
+	cursor := p.cursor
+	p.cursor = p.prefix + runewidth.StringWidth(beforeCursor)
+
+	if text == p.text {
+		return
+	}
+	p.text = text
+
+	pattern := text
+	if br.pattern != "" {
+		pattern = subCommandChars(pattern, "&", br.pattern)
+	}
+
+	if pattern == "" {
+		br.re = p.re
+		br.setSearchField(p.field)
+		br.showPreview(p, p.firstRow, p.firstSeg, cursor)
+		return
+	}
+
+	if len(pattern) > MAX_PATTERN_LENGTH {
+		return
+	}
+
+	field, expr := br.splitFieldSearch(pattern)
+
+	re, err := br.compileSearch(expr)
+	if err != nil {
+		return
+	}
+	br.re = re
+	br.setSearchField(field)
+
+	job := &searchJob{forward: p.forward}
+	timer := time.AfterFunc(INCSEARCH_WAIT, func() { job.cancel.Store(true) })
+	line, _ := br.findSearchMatch(job, p.start, p.mapSize)
+	timer.Stop()
+
+	// measure against the page the prompt started on
+	br.firstRow, br.firstSeg, br.lastRow = p.firstRow, p.firstSeg, p.lastRow
+
+	if line < 0 || br.lineOnCurrentPage(line) {
+		br.showPreview(p, p.firstRow, p.firstSeg, cursor)
+		return
+	}
+
+	br.showPreview(p, br.searchDisplayTop(line, p.forward), 0, cursor)
+}
+
+// showPreview redraws the page from top and puts the cursor back in the
+// prompt.
+func (br *browseObj) showPreview(p *searchPreview, top, seg, cursor int) {
+	if br.wrapping() {
+		br.printWrapPage(top, seg)
+	} else {
+		br.redrawPage(top)
+	}
+	p.drawn = true
+
+	moveCursor(br.dispRows, cursor+1, false)
+}
+
+// endSearchPreview restores the search pattern the prompt started with.
+// When the prompt was cancelled, the original view returns too.
+func (br *browseObj) endSearchPreview(p *searchPreview, cancelled bool) {
+	if p == nil {
+		return
+	}
+
+	br.re = p.re
+	br.setSearchField(p.field)
+
+	if !cancelled || !p.drawn {
+		return
+	}
+
+	br.firstRow, br.firstSeg, br.lastRow = p.firstRow, p.firstSeg, p.lastRow
+	br.shiftWidth = p.shiftWidth
+	br.pageCurrent()
+}
+
+// vim: set ts=4 sw=4 noet:
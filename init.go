@@ -29,9 +29,6 @@ func (br *browseObj) fileInit(fp *os.File, fileName, title string, fromStdin boo
 	br.fileName = fileName
 	br.fp = fp
 	br.fromStdin = fromStdin
-	if !fromStdin {
-		br.stdinEOF = false
-	}
 	br.lastMatch = SEARCH_RESET
 	br.fileSeq++
 	br.mutex.Unlock()
@@ -40,6 +37,9 @@ func (br *browseObj) fileInit(fp *os.File, fileName, title string, fromStdin boo
 		_ = unix.Close(rescueFd)
 	}
 
+	// let the previous file's reader notice and exit
+	br.wakeReader()
+
 	if br.initTitle != "" {
 		// one-time use of -t option
 		br.title = br.initTitle
@@ -61,14 +61,10 @@ func (br *browseObj) screenInit(tty *os.File) {
 	}
 
 	if tty == nil || err != nil {
-		br.dispWidth = 80
-		br.dispHeight = 25
-	} else {
-		br.dispWidth = width
-		br.dispHeight = height
+		width, height = 80, 25
 	}
 
-	br.dispRows = br.dispHeight - 1
+	br.setScreenSize(width, height)
 }
 
 // vim: set ts=4 sw=4 noet:
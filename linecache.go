@@ -0,0 +1,296 @@
+// linecache.go
+// persistent line index for large files
+//
+// Copyright (c) 2024-2026 jjb
+// All rights reserved.
+//
+// This source code is licensed under the MIT license found
+// in the root directory of this source tree.
+
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"hash/fnv"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"golang.org/x/sys/unix"
+)
+
+// Line index cache limits.
+const (
+	// files smaller than this are cheap enough to scan
+	INDEX_CACHE_MIN = 64 << 20
+
+	// blocks of the indexed bytes hashed to detect rewrites
+	INDEX_SAMPLES     = 16
+	INDEX_SAMPLE_SIZE = 4096
+
+	// growth worth saving a larger index for
+	INDEX_RESAVE_MIN = 4 << 20
+
+	// cache files kept in the index directory
+	INDEX_CACHE_MAX = 32
+
+	indexDirName = "index"
+	indexMagic   = "BRIDX2\n"
+)
+
+// lineIndex is a saved seekMap for a file, valid up to size bytes.
+type lineIndex struct {
+	path       string
+	inode      uint64
+	size       int64
+	mtime      int64
+	sampleHash uint64
+	seekMap    []int64
+}
+
+// indexCachePath returns the cache file used for a path.
+func indexCachePath(fileName string) string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+
+	sum := sha256.Sum256([]byte(fileName))
+	return filepath.Join(home, RCDIRNAME, indexDirName, hex.EncodeToString(sum[:12])+".idx")
+}
+
+// fileSampleHash hashes INDEX_SAMPLES blocks spread evenly over the first
+// size bytes, the last of them ending at size.
+func fileSampleHash(fp *os.File, size int64) (uint64, error) {
+	h := fnv.New64a()
+	buf := make([]byte, min(size, INDEX_SAMPLE_SIZE))
+	span := size - int64(len(buf))
+
+	for i := range int64(INDEX_SAMPLES) {
+		start := span * i / (INDEX_SAMPLES - 1)
+		if _, err := fp.ReadAt(buf, start); err != nil && err != io.EOF {
+			return 0, err
+		}
+		h.Write(buf)
+	}
+
+	return h.Sum64(), nil
+}
+
+// loadLineIndex returns the saved index for fileName when the file is
+// unchanged or has only grown since it was saved. Stale caches are removed.
+func loadLineIndex(fileName string, fp *os.File) *lineIndex {
+	cachePath := indexCachePath(fileName)
+	if cachePath == "" {
+		return nil
+	}
+
+	var stat unix.Stat_t
+	if err := unix.Fstat(int(fp.Fd()), &stat); err != nil {
+		return nil
+	}
+
+	if stat.Size < INDEX_CACHE_MIN {
+		// too small to need one, or truncated since
+		os.Remove(cachePath)
+		return nil
+	}
+
+	idx, err := readLineIndex(cachePath)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			os.Remove(cachePath)
+		}
+		return nil
+	}
+
+	if idx.path != fileName || idx.inode != stat.Ino || stat.Size < idx.size {
+		// replaced or truncated
+		os.Remove(cachePath)
+		return nil
+	}
+
+	if stat.Size == idx.size {
+		if stat.Mtim.Nano() == idx.mtime {
+			return idx
+		}
+
+		// rewritten in place
+		os.Remove(cachePath)
+		return nil
+	}
+
+	// grown: trust the index only if the indexed bytes look intact
+	if sampleHash, err := fileSampleHash(fp, idx.size); err != nil || sampleHash != idx.sampleHash {
+		os.Remove(cachePath)
+		return nil
+	}
+
+	return idx
+}
+
+// readLineIndex decodes a cache file.
+func readLineIndex(cachePath string) (*lineIndex, error) {
+	fin, err := os.Open(cachePath)
+	if err != nil {
+		return nil, err
+	}
+	defer fin.Close()
+
+	r := bufio.NewReaderSize(fin, 256*1024)
+
+	magic := make([]byte, len(indexMagic))
+	if _, err := io.ReadFull(r, magic); err != nil || string(magic) != indexMagic {
+		return nil, errors.New("bad index magic")
+	}
+
+	var header [6]uint64
+	for i := range header {
+		if header[i], err = binary.ReadUvarint(r); err != nil {
+			return nil, err
+		}
+	}
+
+	pathLen, lineCount := header[4], header[5]
+	if pathLen > unix.PathMax || lineCount > uint64(header[1]) {
+		return nil, errors.New("bad index header")
+	}
+
+	path := make([]byte, pathLen)
+	if _, err := io.ReadFull(r, path); err != nil {
+		return nil, err
+	}
+
+	idx := &lineIndex{
+		path:       string(path),
+		inode:      header[0],
+		size:       int64(header[1]),
+		mtime:      int64(header[2]),
+		sampleHash: header[3],
+		seekMap:    make([]int64, 0, lineCount),
+	}
+
+	var offset int64
+	for range lineCount {
+		delta, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, err
+		}
+		offset += int64(delta)
+		idx.seekMap = append(idx.seekMap, offset)
+	}
+
+	if len(idx.seekMap) > 0 && idx.seekMap[len(idx.seekMap)-1] >= idx.size {
+		return nil, errors.New("bad index offsets")
+	}
+
+	return idx, nil
+}
+
+// saveLineIndex writes the index of the first size bytes of fileName.
+// seekMap holds line offsets without the SOF entry and must not change
+// while the save runs.
+func saveLineIndex(fileName string, inode uint64, size int64, seekMap []int64) {
+	cachePath := indexCachePath(fileName)
+	if cachePath == "" || size < INDEX_CACHE_MIN {
+		return
+	}
+
+	fp, err := os.Open(fileName)
+	if err != nil {
+		return
+	}
+	defer fp.Close()
+
+	var stat unix.Stat_t
+	if err := unix.Fstat(int(fp.Fd()), &stat); err != nil || stat.Ino != inode {
+		return
+	}
+
+	sampleHash, err := fileSampleHash(fp, size)
+	if err != nil {
+		return
+	}
+
+	cacheDir := filepath.Dir(cachePath)
+	if err := os.MkdirAll(cacheDir, 0700); err != nil {
+		return
+	}
+
+	fout, err := os.CreateTemp(cacheDir, "idx")
+	if err != nil {
+		return
+	}
+	defer os.Remove(fout.Name())
+
+	w := bufio.NewWriterSize(fout, 256*1024)
+	varint := make([]byte, binary.MaxVarintLen64)
+	putUvarint := func(v uint64) {
+		n := binary.PutUvarint(varint, v)
+		w.Write(varint[:n])
+	}
+
+	// mtime only matters if the size is unchanged
+	mtime := stat.Mtim.Nano()
+	if stat.Size != size {
+		mtime = 0
+	}
+
+	w.WriteString(indexMagic)
+	putUvarint(stat.Ino)
+	putUvarint(uint64(size))
+	putUvarint(uint64(mtime))
+	putUvarint(sampleHash)
+	putUvarint(uint64(len(fileName)))
+	putUvarint(uint64(len(seekMap)))
+	w.WriteString(fileName)
+
+	var prev int64
+	for _, offset := range seekMap {
+		putUvarint(uint64(offset - prev))
+		prev = offset
+	}
+
+	if w.Flush() != nil || fout.Close() != nil {
+		return
+	}
+
+	if os.Rename(fout.Name(), cachePath) == nil {
+		pruneLineIndexes(cacheDir)
+	}
+}
+
+// pruneLineIndexes removes the oldest cache files beyond INDEX_CACHE_MAX.
+func pruneLineIndexes(cacheDir string) {
+	entries, err := filepath.Glob(filepath.Join(cacheDir, "*.idx"))
+	if err != nil || len(entries) <= INDEX_CACHE_MAX {
+		return
+	}
+
+	type cacheFile struct {
+		name  string
+		mtime int64
+	}
+
+	files := make([]cacheFile, 0, len(entries))
+	for _, name := range entries {
+		if info, err := os.Stat(name); err == nil {
+			files = append(files, cacheFile{name, info.ModTime().UnixNano()})
+		}
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].mtime > files[j].mtime
+	})
+
+	for _, f := range files[min(INDEX_CACHE_MAX, len(files)):] {
+		os.Remove(f.name)
+	}
+}
+
+// vim: set ts=4 sw=4 noet:
@@ -0,0 +1,187 @@
+// logcolor.go
+// color log lines by level and dim their timestamps
+//
+// Copyright (c) 2024-2026 jjb
+// All rights reserved.
+//
+// This source code is licensed under the MIT license found
+// in the root directory of this source tree.
+
+package main
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Log coloring styles. The zero value is the default.
+const (
+	// the level token is colored
+	LOG_LEVEL = iota
+
+	// lines at WARN and above are colored whole
+	LOG_LINE
+
+	// log lines are not colored
+	LOG_OFF
+)
+
+// logStyleNames name the styles for --log and the session file.
+var logStyleNames = []string{
+	LOG_LEVEL: "level",
+	LOG_LINE:  "line",
+	LOG_OFF:   "off",
+}
+
+// Log levels, most severe first.
+const (
+	LEVEL_FATAL = iota
+	LEVEL_ERROR
+	LEVEL_WARN
+	LEVEL_INFO
+	LEVEL_DEBUG
+)
+
+// levelColors are the colors of the log levels.
+var levelColors = []string{
+	LEVEL_FATAL: _VID_BOLD + _VID_RED_FG,
+	LEVEL_ERROR: _VID_RED_FG,
+	LEVEL_WARN:  _VID_ORANGE_FG,
+	LEVEL_INFO:  _VID_CYAN_FG,
+	LEVEL_DEBUG: _VID_GREY_FG,
+}
+
+// levelNames map level words, lowercased, to levels.
+var levelNames = map[string]int{
+	"fatal":    LEVEL_FATAL,
+	"panic":    LEVEL_FATAL,
+	"crit":     LEVEL_FATAL,
+	"critical": LEVEL_FATAL,
+	"emerg":    LEVEL_FATAL,
+	"alert":    LEVEL_FATAL,
+	"error":    LEVEL_ERROR,
+	"err":      LEVEL_ERROR,
+	"warn":     LEVEL_WARN,
+	"warning":  LEVEL_WARN,
+	"info":     LEVEL_INFO,
+	"notice":   LEVEL_INFO,
+	"debug":    LEVEL_DEBUG,
+	"trace":    LEVEL_DEBUG,
+}
+
+var (
+	// a timestamp starting the line, optionally in brackets: ISO 8601,
+	// syslog, or time of day
+	logTimeRe = regexp.MustCompile(`^\[?(?:\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}:\d{2}(?:[.,]\d+)?(?:Z|[+-]\d{2}:?\d{2})?|` +
+		`[A-Z][a-z]{2} [ \d]\d \d{2}:\d{2}:\d{2}|\d{2}:\d{2}:\d{2}(?:[.,]\d+)?)\]?`)
+
+	// a level given as a JSON or logfmt field
+	logFieldRe = regexp.MustCompile(`(?i)(?:"(?:level|lvl|severity)"\s*:\s*"|\b(?:level|lvl|severity)="?)([a-z]+)`)
+
+	// a bare level word
+	logWordRe = regexp.MustCompile(`\b(?:FATAL|PANIC|CRIT|CRITICAL|EMERG|ALERT|ERROR|ERR|WARN|WARNING|INFO|NOTICE|DEBUG|TRACE)\b`)
+
+	// rotated logs such as app.log.1
+	logNameRe = regexp.MustCompile(`\.log(?:\.\d+)?$`)
+)
+
+// isLogName reports whether a file name looks like a log file.
+func isLogName(fileName string) bool {
+	base := filepath.Base(fileName)
+	for _, ext := range compressedExts {
+		base = strings.TrimSuffix(base, ext)
+	}
+
+	return logNameRe.MatchString(base)
+}
+
+// parseLogStyle returns the style with a name, or -1.
+func parseLogStyle(name string) int {
+	for style, n := range logStyleNames {
+		if n == name {
+			return style
+		}
+	}
+
+	return -1
+}
+
+// lineColors returns the colors of a line: its own, or those of its
+// language, or those of its log level.
+func (br *browseObj) lineColors(line []byte, spans []sgrSpan) []sgrSpan {
+	if spans = br.syntaxSpans(line, spans); spans != nil {
+		return spans
+	}
+
+	if !br.logView || br.logStyle == LOG_OFF {
+		return nil
+	}
+
+	return logSpans(line, br.logStyle)
+}
+
+// logSpans dims the timestamp of a log line and colors its level, or the
+// rest of the line in LOG_LINE style.
+func logSpans(line []byte, style int) []sgrSpan {
+	var spans []sgrSpan
+
+	stamp := 0
+	if loc := logTimeRe.FindIndex(line); loc != nil {
+		stamp = loc[1]
+		spans = append(spans, sgrSpan{pos: 0, sgr: _VID_DIM}, sgrSpan{pos: stamp, sgr: _VID_OFF})
+	}
+
+	level, start, end := logLevel(line[stamp:])
+	if level < 0 {
+		return spans
+	}
+
+	if style == LOG_LINE {
+		if level <= LEVEL_WARN {
+			spans = append(spans, sgrSpan{pos: stamp, sgr: levelColors[level]})
+		}
+		return spans
+	}
+
+	return append(spans,
+		sgrSpan{pos: stamp + start, sgr: levelColors[level]},
+		sgrSpan{pos: stamp + end, sgr: _VID_OFF})
+}
+
+// logLevel finds the level of a log line and where it is named, or
+// returns -1. A JSON or logfmt field wins over a bare word.
+func logLevel(line []byte) (int, int, int) {
+	if m := logFieldRe.FindSubmatchIndex(line); m != nil {
+		if level, ok := levelNames[strings.ToLower(string(line[m[2]:m[3]]))]; ok {
+			return level, m[2], m[3]
+		}
+	}
+
+	if m := logWordRe.FindIndex(line); m != nil {
+		return levelNames[strings.ToLower(string(line[m[0]:m[1]]))], m[0], m[1]
+	}
+
+	return -1, 0, 0
+}
+
+// cycleLogStyle colors the file as a log, or moves on to the next style
+// if it is colored already.
+func (br *browseObj) cycleLogStyle() {
+	switch {
+
+	case !br.logView:
+		br.logView = true
+		if br.logStyle == LOG_OFF {
+			br.logStyle = LOG_LEVEL
+		}
+
+	default:
+		br.logStyle = (br.logStyle + 1) % len(logStyleNames)
+	}
+
+	br.pageCurrent()
+	br.printMessage("Log coloring: "+logStyleNames[br.logStyle], MSG_GREEN)
+}
+
+// vim: set ts=4 sw=4 noet:
@@ -33,11 +33,21 @@ func main() {
 
 	followFlag := getopt.BoolLong("follow", 'f', "follow file")
 	tailFlag := getopt.BoolLong("tail", 'F', "fast follow")
+	encodingStr := getopt.StringLong("encoding", 'E', "", "file encoding")
 	caseFlag := getopt.BoolLong("ignore-case", 'i', "search ignores case")
 	fixedFlag := getopt.BoolLong("fixed-case", 'I', "search fixed case")
+	smartFlag := getopt.BoolLong("smart-case", 's', "search smart case")
+	wordFlag := getopt.BoolLong("word", 'W', "search whole words")
+	listFlag := getopt.BoolLong("search-list", 'S', "search the file list")
 	numberFlag := getopt.BoolLong("numbers", 'n', "line numbers")
 	patternStr := getopt.StringLong("pattern", 'p', "", "search pattern")
 	titleStr := getopt.StringLong("title", 't', "", "page title")
+	wrapFlag := getopt.BoolLong("wrap", 'w', "wrap long lines")
+	colorFlag := getopt.BoolLong("color", 'R', "show ANSI colors")
+	caretFlag := getopt.BoolLong("caret", 'C', "show controls as ^X")
+	syntaxFlag := getopt.BoolLong("syntax", 'y', "syntax coloring")
+	logStr := getopt.StringLong("log", 'l', "", "log coloring: level, line or off")
+	statusStr := getopt.StringLong("status", 'T', "", "status line fields, or all")
 	versionFlag := getopt.BoolLong("version", 'v', "print version number")
 	helpFlag := getopt.BoolLong("help", '?', "this message")
 
@@ -87,7 +97,55 @@ func main() {
 		br.searchFixed = *fixedFlag
 	}
 
+	if getopt.IsSet('s') {
+		br.smartCase = *smartFlag
+	}
+
+	if getopt.IsSet('W') {
+		br.wholeWord = *wordFlag
+	}
+
+	if len(*encodingStr) > 0 {
+		enc, err := parseEncoding(*encodingStr)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "browse: %v\n", err)
+			os.Exit(1)
+		}
+		br.encodingOpt = enc
+	}
+
+	br.searchList = *listFlag
 	br.modeNumbers = *numberFlag
+	br.modeWrap = *wrapFlag
+	br.modeSyntax = *syntaxFlag
+
+	if *colorFlag {
+		br.ctrlMode = CTRL_COLOR
+	}
+
+	// caret notation shows everything color mode hides
+	if *caretFlag {
+		br.ctrlMode = CTRL_CARET
+	}
+
+	if len(*logStr) > 0 {
+		style := parseLogStyle(*logStr)
+		if style < 0 {
+			fmt.Fprintf(os.Stderr, "browse: unknown log style: %s\n", *logStr)
+			os.Exit(1)
+		}
+		br.logStyle = style
+	}
+
+	if len(*statusStr) > 0 {
+		fields, err := parseStatusFields(*statusStr)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "browse: %v\n", err)
+			os.Exit(1)
+		}
+		br.statusFields = fields
+		br.modeStatus = true
+	}
 
 	if len(*patternStr) > 0 {
 		br.pattern = *patternStr
@@ -127,16 +185,26 @@ func brVersion() {
 
 // usageMessage prints CLI usage information.
 func usageMessage(arg0 string) {
-	fmt.Printf("Usage: %s [-fFiInv] [-p pattern] [-t title] [filename...]\n",
+	fmt.Printf("Usage: %s [-CfFiInRsSvwWy] [-E encoding] [-l style] [-p pattern] [-t title] [-T fields] [filename...]\n",
 		filepath.Base(arg0))
+	fmt.Print("  -C, --caret        show controls as ^X\n")
 	fmt.Print("  -f, --follow       follow file\n")
 	fmt.Print("  -F, --tail         fast follow\n")
+	fmt.Print("  -E, --encoding     file encoding\n")
 	fmt.Print("  -i, --ignore-case  search ignores case\n")
 	fmt.Print("  -I, --fixed-case   search fixed case\n")
+	fmt.Print("  -s, --smart-case   search smart case\n")
+	fmt.Print("  -W, --word         search whole words\n")
+	fmt.Print("  -l, --log          log coloring: level, line or off\n")
 	fmt.Print("  -n, --numbers      line numbers\n")
 	fmt.Print("  -p, --pattern      search pattern\n")
+	fmt.Print("  -R, --color        show ANSI colors\n")
+	fmt.Print("  -S, --search-list  search the file list\n")
 	fmt.Print("  -t, --title        page title\n")
+	fmt.Print("  -T, --status       status line fields, or all\n")
 	fmt.Print("  -v, --version      print version number\n")
+	fmt.Print("  -w, --wrap         wrap long lines\n")
+	fmt.Print("  -y, --syntax       syntax coloring\n")
 	fmt.Print("  -?, --help         this message\n")
 }
 
@@ -0,0 +1,223 @@
+// matchcount.go
+// count the lines matching the search pattern in the background
+//
+// Copyright (c) 2024-2026 jjb
+// All rights reserved.
+//
+// This source code is licensed under the MIT license found
+// in the root directory of this source tree.
+
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sync/atomic"
+)
+
+// MATCH_BLOCK_LINES is the number of lines each match count covers. A
+// match's rank is the counts of the blocks before it plus a scan of its
+// own block.
+const MATCH_BLOCK_LINES = 4096
+
+// matchCount counts the lines matching the search pattern so searches can
+// report "match N of M". Lines are counted in batches from the main loop;
+// each batch covers the lines mapped when it starts, so lines appended in
+// follow mode are counted by the next one. Only the number of matches in
+// each block is kept, however many there are.
+type matchCount struct {
+	re      *regexp.Regexp
+	fileSeq uint64
+	mapSeq  uint64
+	blocks  []int
+	total   int
+	scanned int
+	batch   *countBatch
+
+	// match line to report once counting catches up, or -1
+	report int
+}
+
+// countBatch counts matching lines in its own goroutine.
+type countBatch struct {
+	start  int
+	end    int
+	cancel atomic.Bool
+	done   chan struct{}
+
+	// set before done is closed: the matches in each block from the
+	// one holding start
+	blocks []int
+}
+
+// add adds the counts of a finished batch.
+func (c *matchCount) add(b *countBatch) {
+	first := b.start / MATCH_BLOCK_LINES
+
+	for i, n := range b.blocks {
+		for first+i >= len(c.blocks) {
+			c.blocks = append(c.blocks, 0)
+		}
+
+		c.blocks[first+i] += n
+		c.total += n
+	}
+
+	c.scanned = b.end
+}
+
+// syncMatchCount starts a count for a new pattern or file, collects a
+// finished batch, and counts lines mapped since. The main loop calls it
+// while idle.
+func (br *browseObj) syncMatchCount() {
+	c := br.count
+	if br.re == nil || br.modeHex {
+		if c != nil {
+			c.stop()
+			br.count = nil
+		}
+		return
+	}
+
+	br.mutex.Lock()
+	fileSeq := br.fileSeq
+	mapSeq := br.mapSeq
+	mapSize := br.mapSiz
+	br.mutex.Unlock()
+
+	// a truncated or filtered file maps other lines than were counted
+	if c == nil || c.re != br.re || c.fileSeq != fileSeq || c.mapSeq != mapSeq || mapSize < c.scanned {
+		if c != nil {
+			c.stop()
+		}
+
+		// line 0 is the header
+		c = &matchCount{re: br.re, fileSeq: fileSeq, mapSeq: mapSeq, scanned: 1, report: -1}
+		br.count = c
+	}
+
+	if c.batch != nil {
+		select {
+		case <-c.batch.done:
+			c.add(c.batch)
+			c.batch = nil
+
+		default:
+			return
+		}
+	}
+
+	if c.scanned < mapSize {
+		c.batch = br.countLines(c.re, c.scanned, mapSize)
+		return
+	}
+
+	if c.report >= 0 {
+		if c.report == br.lastMatch {
+			br.showMatchPosition(c.report)
+		}
+		c.report = -1
+	}
+}
+
+// countLines starts a batch counting the matching lines from start to end.
+func (br *browseObj) countLines(re *regexp.Regexp, start, end int) *countBatch {
+	b := &countBatch{start: start, end: end, done: make(chan struct{})}
+
+	go func() {
+		defer close(b.done)
+
+		first := start / MATCH_BLOCK_LINES
+		b.blocks = make([]int, (end-1)/MATCH_BLOCK_LINES-first+1)
+
+		var scratch []byte
+		for lineno := start; lineno < end; lineno++ {
+			if b.cancel.Load() {
+				return
+			}
+
+			if br.lineMatches(re, lineno, &scratch) {
+				b.blocks[lineno/MATCH_BLOCK_LINES-first]++
+			}
+		}
+	}()
+
+	return b
+}
+
+// stop abandons a running batch.
+func (c *matchCount) stop() {
+	if c.batch != nil {
+		c.batch.cancel.Store(true)
+	}
+}
+
+// counting reports whether lines remain to be counted.
+func (c *matchCount) counting() bool {
+	return c.batch != nil
+}
+
+// reportMatch shows the position of a match among all matches, at once if
+// the count is complete or when it completes.
+func (br *browseObj) reportMatch(line int) {
+	br.syncMatchCount()
+
+	c := br.count
+	if c == nil {
+		return
+	}
+
+	if c.counting() {
+		c.report = line
+		return
+	}
+
+	br.showMatchPosition(line)
+}
+
+// showMatchPosition prints "Match N of M" for a counted match line.
+func (br *browseObj) showMatchPosition(line int) {
+	c := br.count
+
+	block := line / MATCH_BLOCK_LINES
+	if block >= len(c.blocks) || !br.lineMatches(c.re, line, &br.matchScratch) {
+		return
+	}
+
+	rank := 1
+	for _, n := range c.blocks[:block] {
+		rank += n
+	}
+
+	for lineno := block * MATCH_BLOCK_LINES; lineno < line; lineno++ {
+		if br.lineMatches(c.re, lineno, &br.matchScratch) {
+			rank++
+		}
+	}
+
+	br.printMessage(fmt.Sprintf("Match %d of %d", rank, c.total), MSG_GREEN)
+}
+
+// matchTotal describes the match count for the position message, with a
+// + while counting continues.
+func (br *browseObj) matchTotal() string {
+	br.syncMatchCount()
+
+	c := br.count
+	if c == nil {
+		return ""
+	}
+
+	more := ""
+	if c.counting() {
+		more = "+"
+	}
+
+	if c.total == 1 && !c.counting() {
+		return ", 1 match"
+	}
+
+	return fmt.Sprintf(", %d%s matches", c.total, more)
+}
+
+// vim: set ts=4 sw=4 noet:
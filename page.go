@@ -13,20 +13,37 @@ import (
 	"fmt"
 	"os"
 	"strings"
+
+	"github.com/mattn/go-runewidth"
 )
 
 // pageUp moves up by one screen of content.
 func (br *browseObj) pageUp() {
+	if br.wrapping() {
+		br.printWrapPage(br.wrapBack(br.firstRow, br.firstSeg, br.dispRows, br.currentMapSize()))
+		return
+	}
+
 	br.printPage(br.firstRow - br.dispRows)
 }
 
 // pageCurrent redraws the current screen.
 func (br *browseObj) pageCurrent() {
+	if br.wrapping() {
+		br.printWrapPage(br.firstRow, br.firstSeg)
+		return
+	}
+
 	br.printPage(br.firstRow)
 }
 
 // pageDown advances by one screen of content.
 func (br *browseObj) pageDown() {
+	if br.wrapping() {
+		br.printWrapPage(br.lastRow, br.lastSeg)
+		return
+	}
+
 	br.printPage(br.firstRow + br.dispRows)
 }
 
@@ -34,32 +51,55 @@ func (br *browseObj) pageDown() {
 func (br *browseObj) pageHeader() {
 	const minHeaderWidth = 10
 
+	if br.split != nil {
+		br.split.drawFrame()
+		return
+	}
+
 	// Validate display width
 	if br.dispWidth < minHeaderWidth {
 		return
 	}
 
+	var sb strings.Builder
+
+	sb.WriteString(fmt.Sprintf(CURPOS, 1, 1))
+	sb.WriteString(CLEARSCREEN)
+	sb.WriteString(LINEWRAPOFF)
+	sb.WriteString(fmt.Sprintf(SCROLLREGION, br.paneTop+2, br.paneTop+br.dispHeight))
+	sb.WriteString(headerBar(br.title, br.dispWidth, VIDBOLDREV))
+	os.Stdout.WriteString(sb.String())
+
+	// the screen was cleared under the status line
+	br.statusShown = ""
+}
+
+// headerBar returns a header width columns wide with the title centered
+// in attr.
+func headerBar(title string, width int, attr string) string {
 	// Calculate available width for title (account for tees and spaces)
-	availableWidth := br.dispWidth - 4
+	availableWidth := width - 4
 
 	// Prepare title with ellipsis if needed
-	dispTitle := br.title
-	if len(br.title) > availableWidth {
-		// Calculate start index for the title substring
+	dispTitle := title
+	titleWidth := runewidth.StringWidth(title)
+	if titleWidth > availableWidth {
+		// Drop columns from the left of the title
 		// Leave room for ellipsis (3 chars) and some title text
-		startIndex := min(max(len(br.title)-(availableWidth-7), 0), len(br.title))
-		dispTitle = "..." + br.title[startIndex:]
+		cut := min(max(titleWidth-(availableWidth-7), 0), titleWidth)
+		dispTitle = runewidth.TruncateLeft(title, cut, "...")
 	}
 
 	// Calculate padding for centering
-	padding := (availableWidth - len(dispTitle)) >> 1
+	dispWidth := runewidth.StringWidth(dispTitle)
+	padding := (availableWidth - dispWidth) >> 1
 
 	// build header
 	// ─────┤ title ├─────
 
 	var sb strings.Builder
 
-	sb.Grow(br.dispWidth + 20)
+	sb.Grow(width + 20)
 
 	// left side
 	sb.WriteString(ENTERGRAPHICS)
@@ -68,7 +108,7 @@ func (br *browseObj) pageHeader() {
 	sb.WriteString(EXITGRAPHICS)
 
 	// title
-	sb.WriteString(VIDBOLDREV)
+	sb.WriteString(attr)
 	sb.WriteString(" ")
 	sb.WriteString(dispTitle)
 	sb.WriteString(" ")
@@ -77,19 +117,10 @@ func (br *browseObj) pageHeader() {
 	// right side
 	sb.WriteString(ENTERGRAPHICS)
 	sb.WriteString(RIGHTTEE)
-	sb.WriteString(strings.Repeat(HORIZLINE, availableWidth-padding-len(dispTitle)))
+	sb.WriteString(strings.Repeat(HORIZLINE, availableWidth-padding-dispWidth))
 	sb.WriteString(EXITGRAPHICS)
 
-	// display header
-	header := sb.String()
-	sb.Reset()
-
-	sb.WriteString(fmt.Sprintf(CURPOS, 1, 1))
-	sb.WriteString(CLEARSCREEN)
-	sb.WriteString(LINEWRAPOFF)
-	sb.WriteString(fmt.Sprintf(SCROLLREGION, 2, br.dispHeight))
-	sb.WriteString(header)
-	os.Stdout.WriteString(sb.String())
+	return sb.String()
 }
 
 // pageLast jumps to the end of the file.
@@ -15,6 +15,9 @@ import (
 	"strings"
 	"sync"
 	"time"
+	"unicode/utf8"
+
+	"github.com/mattn/go-runewidth"
 )
 
 var lineBufPool = sync.Pool{
@@ -73,12 +76,7 @@ func (br *browseObj) printLineWithMapSize(lineno, mapSize int) {
 		return
 	}
 
-	// Get content from map. Search owns br.lastMatch; rendering must not move it.
-	// Read directly: replaceMatch re-runs the regex itself, so a match here
-	// would be thrown away.
-	input := br.readFromMap(lineno)
-
-	output := br.replaceMatch(lineno, input)
+	output := br.lineText(lineno)
 
 	// Use a pooled Builder for line output, reducing allocations and Print calls
 	lineBuf := lineBufPool.Get().(*strings.Builder)
@@ -96,8 +94,53 @@ func (br *browseObj) printLineWithMapSize(lineno, mapSize int) {
 	}
 }
 
+// lineText returns a line as it is displayed, with its highlights and
+// colors.
+func (br *browseObj) lineText(lineno int) string {
+	if br.modeHex {
+		return br.hexRow(lineno)
+	}
+
+	// Get content from map. Search owns br.lastMatch; rendering must not move it.
+	// Read directly: replaceMatch re-runs the regex itself, so a match here
+	// would be thrown away.
+	var input []byte
+	var spans []sgrSpan
+	if br.tabular() {
+		input = br.tableLine(lineno)
+	} else {
+		input, spans = br.readVisible(lineno)
+	}
+
+	// Clipped lines only need what can be shifted into view; a column
+	// takes up to utf8.UTFMax bytes
+	if clip := (br.shiftWidth + READBUFSIZ) * utf8.UTFMax; len(input) > clip {
+		input = input[:clip]
+	}
+
+	spans = br.lineColors(input, spans)
+	output := br.replaceMatch(lineno, input, spans)
+
+	// the listing cursor stays reversed through match highlights
+	if br.dirList != nil && lineno == br.dirCursor {
+		output = _VID_REV + strings.ReplaceAll(output, VIDOFF, VIDOFF+_VID_REV)
+	}
+
+	return output
+}
+
 // printPage renders a page starting at the provided top line.
 func (br *browseObj) printPage(lineno int) {
+	if br.repaints() {
+		br.paintPane(lineno, 0)
+		return
+	}
+
+	if br.wrapping() {
+		br.printWrapPage(lineno, 0)
+		return
+	}
+
 	mapSize := br.currentMapSize()
 
 	lineno = adjustLineNumber(lineno, br.dispRows, mapSize)
@@ -109,6 +152,30 @@ func (br *browseObj) printPage(lineno int) {
 		return
 	}
 
+	br.drawPage(sop, eop, mapSize)
+}
+
+// redrawPage renders a page starting at the provided top line without
+// scrolling the screen, so a prompt on the screen stays put.
+func (br *browseObj) redrawPage(lineno int) {
+	if br.repaints() {
+		br.paintPane(lineno, 0)
+		return
+	}
+
+	if br.wrapping() {
+		br.printWrapPage(lineno, 0)
+		return
+	}
+
+	mapSize := br.currentMapSize()
+
+	sop := adjustLineNumber(lineno, br.dispRows, mapSize)
+	br.drawPage(sop, minimum(sop+br.dispRows, mapSize+1), mapSize)
+}
+
+// drawPage renders lines sop through eop-1 from the top of the screen.
+func (br *browseObj) drawPage(sop, eop, mapSize int) {
 	// Only one cursor move here for all lines
 	// printLine starts with \n
 	moveCursor(1, 1, false)
@@ -131,6 +198,7 @@ func (br *browseObj) printCurrentList() {
 	// Leave room for ellipsis (3 chars)
 	maxLen := br.dispWidth - 8
 
+	width := 0
 	for i, name := range br.currentList {
 		// Add brackets to the current file in the list
 		if i == 0 {
@@ -139,12 +207,14 @@ func (br *browseObj) printCurrentList() {
 			name = " " + name
 		}
 
-		if sb.Len()+len(name) > maxLen {
+		nameWidth := runewidth.StringWidth(name)
+		if width+nameWidth > maxLen {
 			sb.WriteString(" ...")
 			break
 		}
 
 		sb.WriteString(name)
+		width += nameWidth
 	}
 
 	br.printMessage(sb.String(), MSG_GREEN)
@@ -170,6 +240,11 @@ func adjustLineNumber(lineno, dispRows, mapSiz int) int {
 
 // timedMessage displays a temporary message on the status line.
 func (br *browseObj) timedMessage(msg, color string) {
+	if br.split != nil {
+		// messages stay in their pane
+		msg = runewidth.Truncate(msg, br.dispWidth-2, "...")
+	}
+
 	moveCursor(br.dispHeight, 1, true)
 	var sb strings.Builder
 	sb.Grow(len(msg) + len(color) + len(VIDOFF) + 8)
@@ -186,6 +261,11 @@ func (br *browseObj) timedMessage(msg, color string) {
 
 // printMessage displays a message on the status line.
 func (br *browseObj) printMessage(msg string, color string) {
+	if br.split != nil {
+		// messages stay in their pane
+		msg = runewidth.Truncate(msg, br.dispWidth-2, "...")
+	}
+
 	moveCursor(br.dispHeight, 1, true)
 	var sb strings.Builder
 	sb.Grow(len(msg) + len(color) + len(VIDOFF) + 8)
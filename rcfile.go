@@ -55,6 +55,24 @@ func (br *browseObj) writeRcFile() bool {
 	data.WriteString(strconv.FormatBool(br.searchFixed))
 	data.WriteByte('\n')
 
+	// smartCase
+	data.WriteString(strconv.FormatBool(br.smartCase))
+	data.WriteByte('\n')
+
+	// wholeWord
+	data.WriteString(strconv.FormatBool(br.wholeWord))
+	data.WriteByte('\n')
+
+	// logStyle
+	data.WriteString(logStyleNames[br.logStyle])
+	data.WriteByte('\n')
+
+	// pinned patterns, one per line
+	for _, p := range br.pinned {
+		data.WriteString(formatPin(p))
+		data.WriteByte('\n')
+	}
+
 	// save
 	err := os.WriteFile(rcFileName, []byte(data.String()), 0644)
 
@@ -75,11 +93,7 @@ func (br *browseObj) readRcFile() bool {
 	scanner := bufio.NewScanner(fp)
 
 	linesRead := 0
-	for i := range 7 {
-		if !scanner.Scan() {
-			break
-		}
-
+	for i := 0; scanner.Scan(); i++ {
 		linesRead++
 		line := strings.TrimRight(scanner.Text(), "\r\n")
 
@@ -137,6 +151,34 @@ func (br *browseObj) handleRcFileLine(i int, line string) bool {
 			return false
 		}
 		br.searchFixed = searchFixed
+
+	case 7:
+		// smartCase, absent from older files that go on to pins
+		smartCase, err := strconv.ParseBool(line)
+		if err != nil {
+			return br.parsePin(line)
+		}
+		br.smartCase = smartCase
+
+	case 8:
+		// wholeWord
+		wholeWord, err := strconv.ParseBool(line)
+		if err != nil {
+			return br.parsePin(line)
+		}
+		br.wholeWord = wholeWord
+
+	case 9:
+		// logStyle
+		style := parseLogStyle(line)
+		if style < 0 {
+			return br.parsePin(line)
+		}
+		br.logStyle = style
+
+	default:
+		// pinned patterns
+		return br.parsePin(line)
 	}
 
 	return true
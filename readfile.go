@@ -14,7 +14,7 @@ import (
 	"fmt"
 	"io"
 	"os"
-	"time"
+	"strings"
 
 	"golang.org/x/sys/unix"
 )
@@ -28,12 +28,23 @@ func readInit(br *browseObj, bytesRead *int64) {
 	br.savFileSiz = 0
 	br.newInode = 0
 	br.savInode = 0
+	br.scanLines = 0
+	br.lineMap = nil
+	br.fileSeekMap = nil
+	br.fileSizeMap = nil
+	if br.filter != nil {
+		// every line is kept too, so another filter can be mapped from them
+		br.lineMap = []int{0}
+		br.fileSeekMap = []int64{0}
+		br.fileSizeMap = []int64{0}
+	}
+	br.mapSeq++
 	*bytesRead = 0
 }
 
 // readFile continuously reads a file and updates line maps.
 func readFile(br *browseObj, ch chan bool) {
-	var bytesRead int64
+	var bytesRead, indexedSize int64
 	var err error
 	initialRead := true
 
@@ -65,13 +76,27 @@ func readFile(br *browseObj, ch chan bool) {
 	fd := int(readerFp.Fd())
 
 	// Get initial filename with mutex protection
+	wake := make(chan struct{}, 1)
 	br.mutex.Lock()
 	savFileName := br.fileName
 	savFileSeq := br.fileSeq
+	hexMode := br.modeHex
+	encoding := br.encoding
+	filter := br.filter
+	ctrlMode := br.ctrlMode
+	br.readerWake = wake
 	br.mutex.Unlock()
 
+	// inotify wakes us on change; unsupported filesystems are polled
+	watcher := newFileWatcher(savFileName)
+	defer watcher.close()
+
 	bufReader := bufio.NewReader(readerFp)
-	type lineMeta struct{ offset, length int64 }
+	newline := encodedNewline(encoding)
+	type lineMeta struct {
+		offset, length int64
+		keep           bool
+	}
 	pendingLines := make([]lineMeta, 0, 1024)
 	var postRereadRefresh bool
 	var rereadDetected bool
@@ -125,14 +150,17 @@ func readFile(br *browseObj, ch chan bool) {
 		initialRead = true
 		savFileName = target
 		rereadDetected = false
+		watcher.watch(target)
 		return nil
 	}
 
 	for {
-		// Get current filename snapshot under lock
+		// Get current filename snapshot under lock; a filter job may have
+		// changed the filter since the last pass
 		br.mutex.Lock()
 		currentFileName := br.fileName
 		currentFileSeq := br.fileSeq
+		filter = br.filter
 		br.mutex.Unlock()
 
 		if currentFileSeq != savFileSeq || currentFileName != savFileName {
@@ -239,7 +267,7 @@ func readFile(br *browseObj, ch chan bool) {
 		// Store the file info we just retrieved
 		br.newFileSiz = newFileSiz
 		br.newInode = newInode
-		stdinFinalPending := br.fromStdin && br.stdinEOF && bytesRead < br.newFileSiz
+		stdinFinalPending := br.streamComplete(savFileName) && bytesRead < br.newFileSiz
 
 		handleFileReset := func(msg string) {
 			if msg != "" {
@@ -264,7 +292,37 @@ func readFile(br *browseObj, ch chan bool) {
 
 		br.mutex.Unlock()
 
-		if shouldRead {
+		if shouldRead && hexMode {
+			// hex records are fixed size; only their count changes
+			if !br.publishHexRecords(savFileSeq) {
+				return
+			}
+			bytesRead = newFileSiz
+			initialRead = false
+
+			select {
+			case ch <- true:
+			default:
+			}
+		} else if shouldRead {
+			// Large files may have a saved index; only the tail needs a scan.
+			// Saved indexes split lines on single-byte newlines.
+			br.mutex.Lock()
+			cacheable := !br.fromStdin && savFileName == br.absFileName && !isUTF16(encoding) && filter == nil &&
+				!isSpoolFile(savFileName)
+			br.mutex.Unlock()
+
+			if cacheable && initialRead && bytesRead == 0 {
+				indexedSize = 0
+				if idx := loadLineIndex(savFileName, readerFp); idx != nil {
+					if !br.publishLineIndex(idx, savFileSeq) {
+						return
+					}
+					bytesRead = idx.size
+					indexedSize = idx.size
+				}
+			}
+
 			// Seek to the last known end of file (or beginning if truncated)
 			if _, err := readerFp.Seek(bytesRead, io.SeekStart); err != nil {
 				select {
@@ -279,7 +337,7 @@ func readFile(br *browseObj, ch chan bool) {
 			pendingLines = pendingLines[:0]
 
 			for {
-				line, err := bufReader.ReadString('\n')
+				line, err := readEncodedLine(bufReader, encoding)
 				if err != nil {
 					if err != io.EOF {
 						// Report and exit for unexpected error
@@ -300,9 +358,11 @@ func readFile(br *browseObj, ch chan bool) {
 				// the next iteration re-reads it once the file has grown. For
 				// stdin, publish the final unterminated line after the copy ends.
 
-				if err == io.EOF && line[lineLen-1] != '\n' {
+				terminated := strings.HasSuffix(line, newline)
+
+				if err == io.EOF && !terminated {
 					br.mutex.Lock()
-					stdinDone := br.fromStdin && br.stdinEOF
+					stdinDone := br.streamComplete(savFileName)
 					br.mutex.Unlock()
 					if !stdinDone {
 						break
@@ -310,12 +370,16 @@ func readFile(br *browseObj, ch chan bool) {
 				}
 
 				readLen := int64(lineLen)
-				if line[lineLen-1] == '\n' {
-					readLen--
+				if terminated {
+					readLen -= int64(len(newline))
 				}
 
-				cappedLen := min(readLen, READBUFSIZ)
-				pendingLines = append(pendingLines, lineMeta{offset: readOffset, length: cappedLen})
+				// filters see the line as it is displayed
+				keep := filter == nil ||
+					filter.keeps(visibleLine([]byte(line[:readLen]), readOffset, encoding, ctrlMode))
+
+				cappedLen := mapLineSize(readLen)
+				pendingLines = append(pendingLines, lineMeta{offset: readOffset, length: cappedLen, keep: keep})
 				readOffset += int64(lineLen)
 
 				if err == io.EOF {
@@ -328,18 +392,44 @@ func readFile(br *browseObj, ch chan bool) {
 				br.mutex.Unlock()
 				return
 			}
+			if br.filter != filter {
+				// the lines were kept by the old filter; read them again
+				br.mutex.Unlock()
+				continue
+			}
 			for _, info := range pendingLines {
+				br.scanLines++
+				if filter != nil {
+					br.fileSeekMap = append(br.fileSeekMap, info.offset)
+					br.fileSizeMap = append(br.fileSizeMap, info.length)
+				}
+				if !info.keep {
+					continue
+				}
+
 				br.seekMap = append(br.seekMap, info.offset)
 				br.sizeMap = append(br.sizeMap, info.length)
 				br.mapSiz++
+				if filter != nil {
+					br.lineMap = append(br.lineMap, br.scanLines)
+				}
 			}
 			if len(pendingLines) > 0 {
 				br.hitEOF = false
 			}
 			br.savFileSiz = br.newFileSiz
 			br.savInode = br.newInode
+			indexed := br.seekMap[1:br.mapSiz]
+			inode := br.savInode
 			br.mutex.Unlock()
 			bytesRead = readOffset
+
+			// The maps are append-only, so the save can share the slice.
+			// Growth is saved too, so a later open scans only what is new.
+			if cacheable && bytesRead >= INDEX_CACHE_MIN && bytesRead-indexedSize >= INDEX_RESAVE_MIN {
+				go saveLineIndex(savFileName, inode, bytesRead, indexed)
+				indexedSize = bytesRead
+			}
 			initialRead = false
 
 			select {
@@ -354,8 +444,84 @@ func readFile(br *browseObj, ch chan bool) {
 			br.pageCurrent()
 		}
 
-		time.Sleep(time.Second)
+		watcher.wait(wake)
+	}
+}
+
+// wakeReader prompts the reader goroutine to check the file now.
+func (br *browseObj) wakeReader() {
+	br.mutex.Lock()
+	wake := br.readerWake
+	br.mutex.Unlock()
+
+	if wake == nil {
+		return
+	}
+
+	select {
+	case wake <- struct{}{}:
+	default:
+	}
+}
+
+// remapFile restarts the reader after change alters how the file maps to
+// lines, as the hex view and control modes do. The page and the marks
+// keep their byte offsets. change runs with br.mutex held.
+func (br *browseObj) remapFile(change func()) bool {
+	offsets := br.viewOffsets()
+
+	br.mutex.Lock()
+	change()
+	br.fileSeq++
+	br.mutex.Unlock()
+
+	// let the current reader notice and exit
+	br.wakeReader()
+
+	syncOK := make(chan bool, 1)
+	go readFile(br, syncOK)
+	if !<-syncOK {
+		br.printMessage("Cannot re-map the file", MSG_RED)
+		return false
+	}
+
+	br.restoreViewOffsets(offsets)
+	return true
+}
+
+// viewOffsets holds the byte offsets of the page and the marks while the
+// file is mapped to lines again.
+type viewOffsets struct {
+	top   int64
+	marks [MAXMARKS]int64
+}
+
+// viewOffsets records the byte offsets of the page and the marks.
+func (br *browseObj) viewOffsets() viewOffsets {
+	var offsets viewOffsets
+	for i, row := range br.marks {
+		offsets.marks[i] = br.rowOffset(row)
+	}
+	offsets.top = br.rowOffset(br.firstRow)
+
+	return offsets
+}
+
+// restoreViewOffsets moves the page and the marks to the lines now
+// holding their byte offsets.
+func (br *browseObj) restoreViewOffsets(offsets viewOffsets) {
+	for i, row := range br.marks {
+		if row > 0 {
+			br.marks[i] = br.offsetRow(offsets.marks[i])
+		}
+	}
+
+	if br.firstRow > 0 {
+		br.firstRow = br.offsetRow(offsets.top)
 	}
+
+	br.firstSeg, br.lastSeg = 0, 0
+	br.lastMatch = SEARCH_RESET
 }
 
 // getFileInodeSize returns the size and inode for a filename.
@@ -370,8 +536,44 @@ func getFileInodeSize(filename string) (int64, uint64, error) {
 	return stat.Size, stat.Ino, nil
 }
 
+// mapLineSize returns the length recorded in sizeMap for a line.
+func mapLineSize(readLen int64) int64 {
+	return min(readLen, MAXLINESIZ)
+}
+
+// publishLineIndex loads a saved index into the line maps. It returns
+// false if the file changed underneath the reader.
+func (br *browseObj) publishLineIndex(idx *lineIndex, fileSeq uint64) bool {
+	br.mutex.Lock()
+	defer br.mutex.Unlock()
+
+	if br.fileSeq != fileSeq {
+		return false
+	}
+
+	for i, offset := range idx.seekMap {
+		next := idx.size
+		if i+1 < len(idx.seekMap) {
+			next = idx.seekMap[i+1]
+		}
+
+		// indexed lines always end in a newline
+		br.seekMap = append(br.seekMap, offset)
+		br.sizeMap = append(br.sizeMap, mapLineSize(next-offset-1))
+	}
+	br.mapSiz += len(idx.seekMap)
+
+	return true
+}
+
+// streamComplete reports whether streamed input, stdin or a spool, has
+// been copied in full. Caller holds br.mutex.
+func (br *browseObj) streamComplete(fileName string) bool {
+	return (br.fromStdin && br.stdinComplete()) || spoolComplete(fileName)
+}
+
 // readStdin copies stdin into a temp file and returns true if empty.
-func (br *browseObj) readStdin(fin, fout *os.File) bool {
+func (br *browseObj) readStdin(fin io.Reader, fout *os.File) bool {
 	const copyBufSize = 64 * 1024
 
 	buf := make([]byte, copyBufSize)
@@ -384,19 +586,46 @@ func (br *browseObj) readStdin(fin, fout *os.File) bool {
 	return bytesWritten == 0
 }
 
-// readFromMap reads a line by index using the seek and size maps.
+// lineExtent returns the offset and length of a line, or of a record in
+// the hex view. Caller holds br.mutex.
+func (br *browseObj) lineExtent(lineno int) (int64, int64) {
+	if br.modeHex {
+		return hexRecordOffset(lineno), HEXWIDTH
+	}
+
+	return br.seekMap[lineno], br.sizeMap[lineno]
+}
+
+// readFromMap reads a line by index using the seek and size maps, as it
+// is shown in the current control mode.
 func (br *browseObj) readFromMap(lineno int) []byte {
+	text, _ := br.readVisible(lineno)
+	return text
+}
+
+// readVisible reads a line like readFromMap, with the color changes in it.
+func (br *browseObj) readVisible(lineno int) ([]byte, []sgrSpan) {
+	br.mutex.Lock()
+	ctrlMode := br.ctrlMode
+	br.mutex.Unlock()
+
+	return visibleText(br.readDecoded(lineno), ctrlMode)
+}
+
+// readDecoded returns a line as UTF-8, with its tabs and control bytes
+// as they are in the file.
+func (br *browseObj) readDecoded(lineno int) []byte {
 	br.mutex.Lock()
 	if lineno >= br.mapSiz || br.fp == nil {
 		br.mutex.Unlock()
 		return nil
 	}
 
-	seek := br.seekMap[lineno]
-	size := br.sizeMap[lineno]
+	seek, size := br.lineExtent(lineno)
+	encoding := br.encoding
 
 	// Make sure size is reasonable to avoid panics (16MB)
-	if size < 0 || size > (16<<20) {
+	if size < 0 || size > MAXLINESIZ {
 		br.mutex.Unlock()
 		return nil
 	}
@@ -408,7 +637,7 @@ func (br *browseObj) readFromMap(lineno int) []byte {
 		return nil
 	}
 
-	return expandTabs(data[:n])
+	return decodeLine(data[:n], seek, encoding)
 }
 
 // vim: set ts=4 sw=4 noet:
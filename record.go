@@ -0,0 +1,408 @@
+// record.go
+// structured view of JSON Lines and logfmt logs
+//
+// Copyright (c) 2024-2026 jjb
+// All rights reserved.
+//
+// This source code is licensed under the MIT license found
+// in the root directory of this source tree.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/mattn/go-runewidth"
+)
+
+// RECORD_INDENT indents nested JSON in an expanded record.
+const RECORD_INDENT = "  "
+
+// record is a line parsed into named fields, in the order they appear.
+// Nested JSON objects are flattened, so user.id names the id field of
+// user.
+type record struct {
+	keys   []string
+	values map[string]string
+}
+
+// add sets a field, keeping the place of one seen before.
+func (r *record) add(key, value string) {
+	if _, ok := r.values[key]; !ok {
+		r.keys = append(r.keys, key)
+	}
+
+	r.values[key] = value
+}
+
+// parseRecord parses a line as a JSON object or as logfmt key=value pairs.
+func parseRecord(line []byte) (*record, bool) {
+	line = bytes.TrimSpace(line)
+	rec := &record{values: make(map[string]string)}
+
+	if bytes.HasPrefix(line, []byte{'{'}) {
+		return rec, json.Valid(line) && rec.addJSON("", line)
+	}
+
+	return rec, rec.addLogfmt(string(line))
+}
+
+// addJSON adds the fields of a JSON object, with prefix before their
+// names.
+func (r *record) addJSON(prefix string, data []byte) bool {
+	dec := json.NewDecoder(bytes.NewReader(data))
+
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return false
+	}
+
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return false
+		}
+
+		key, ok := tok.(string)
+		if !ok {
+			return false
+		}
+
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return false
+		}
+
+		if raw = bytes.TrimSpace(raw); bytes.HasPrefix(raw, []byte{'{'}) {
+			if !r.addJSON(prefix+key+".", raw) {
+				return false
+			}
+			continue
+		}
+
+		r.add(prefix+key, jsonText(raw))
+	}
+
+	return true
+}
+
+// jsonText returns a JSON value as it is shown in a column: strings
+// without their quotes, anything else compacted.
+func jsonText(raw json.RawMessage) string {
+	var s string
+	if bytes.HasPrefix(raw, []byte{'"'}) && json.Unmarshal(raw, &s) == nil {
+		return s
+	}
+
+	var buf bytes.Buffer
+	if json.Compact(&buf, raw) != nil {
+		return string(raw)
+	}
+
+	return buf.String()
+}
+
+// addLogfmt adds logfmt pairs. Every word must be a pair, and there must
+// be two at least, so plain text is not taken for a record.
+func (r *record) addLogfmt(line string) bool {
+	for {
+		line = strings.TrimLeft(line, " \t")
+		if line == "" {
+			break
+		}
+
+		eq := strings.IndexAny(line, "= \t\"")
+		if eq <= 0 || line[eq] != '=' {
+			return false
+		}
+
+		key, value := line[:eq], line[eq+1:]
+
+		if strings.HasPrefix(value, `"`) {
+			end := quotedEnd([]byte(value), 0, true)
+			text, err := strconv.Unquote(value[:end])
+			if err != nil {
+				return false
+			}
+
+			line = value[end:]
+			value = text
+		} else {
+			end := strings.IndexAny(value, " \t")
+			if end < 0 {
+				end = len(value)
+			}
+
+			line = value[end:]
+			value = value[:end]
+		}
+
+		r.add(key, value)
+	}
+
+	return len(r.keys) >= 2
+}
+
+// detectRecords finds the fields of a file whose lines are nearly all
+// JSON objects or logfmt pairs. The columns are the fields in the order
+// they are first seen. It returns nil for other files.
+func detectRecords(fp *os.File) *tableView {
+	lines := sampleLines(fp)
+	if len(lines) == 0 {
+		return nil
+	}
+
+	t := &tableView{}
+	widths := make(map[string]int)
+	parsed := 0
+
+	for _, line := range lines {
+		rec, ok := parseRecord(line)
+		if !ok {
+			continue
+		}
+		parsed++
+
+		for _, key := range rec.keys {
+			if _, seen := widths[key]; !seen {
+				t.names = append(t.names, key)
+				widths[key] = runewidth.StringWidth(fieldText(key))
+			}
+
+			w := runewidth.StringWidth(fieldText(rec.values[key]))
+			widths[key] = min(max(widths[key], w), TABLE_MAX_WIDTH)
+		}
+	}
+
+	if parsed*100 < len(lines)*TABLE_MATCH_PERCENT || len(t.names) == 0 {
+		return nil
+	}
+
+	for i, name := range t.names {
+		t.widths = append(t.widths, widths[name])
+		t.order = append(t.order, i)
+	}
+
+	return t
+}
+
+// detectColumns finds the columns of a file, as the fields of records or
+// as delimited fields. CSV and TSV names are taken at their word.
+func detectColumns(fp *os.File, fileName string) *tableView {
+	if tableName(fileName) == 0 {
+		if t := detectRecords(fp); t != nil {
+			return t
+		}
+	}
+
+	return detectTable(fp, fileName)
+}
+
+// expand returns a line laid out one field to a line, with the names
+// aligned. JSON records are indented as they are nested.
+func (t *tableView) expand(line []byte) string {
+	if t.records() {
+		var buf bytes.Buffer
+		if json.Indent(&buf, bytes.TrimSpace(line), "", RECORD_INDENT) == nil {
+			return buf.String() + "\n"
+		}
+	}
+
+	names, values := t.names, []string(nil)
+
+	if t.records() {
+		rec, ok := parseRecord(line)
+		if !ok {
+			return string(line) + "\n"
+		}
+
+		names = rec.keys
+		for _, key := range rec.keys {
+			values = append(values, rec.values[key])
+		}
+	} else {
+		values = splitFields(line, t.delim)
+	}
+
+	width := 0
+	for _, name := range names {
+		width = max(width, runewidth.StringWidth(name))
+	}
+
+	var sb strings.Builder
+	for i, name := range names {
+		value := ""
+		if i < len(values) {
+			value = values[i]
+		}
+
+		fmt.Fprintf(&sb, "%s  %s\n", runewidth.FillRight(name, width), value)
+	}
+
+	return sb.String()
+}
+
+// currentRecord returns the line an expansion shows: the last match when
+// it is on the page, or else the top line.
+func (br *browseObj) currentRecord() int {
+	if br.lastMatch > 0 && br.lineOnCurrentPage(br.lastMatch) {
+		return br.lastMatch
+	}
+
+	lineno := max(br.firstRow, 1)
+	if lineno == 1 && br.table.headerLine() {
+		lineno++
+	}
+
+	return lineno
+}
+
+// expandRecord shows the current record pretty-printed in a nested view.
+// It returns true when the file must be reopened, as runGrep does.
+func (br *browseObj) expandRecord() bool {
+	if !br.tabular() {
+		br.printMessage("Not in column view", MSG_ORANGE)
+		return false
+	}
+
+	lineno := br.currentRecord()
+	if lineno >= br.currentMapSize() {
+		br.printMessage("No record to expand", MSG_ORANGE)
+		return false
+	}
+
+	text := br.table.expand(br.readDecoded(lineno))
+
+	title := fmt.Sprintf("line %d", br.origLine(lineno))
+	if !br.fromStdin {
+		title += " " + filepath.Base(br.sourceName())
+	}
+
+	sp, err := newSpool(io.NopCloser(strings.NewReader(text)), title, len(text))
+	if err != nil {
+		br.printMessage(err.Error(), MSG_RED)
+		return false
+	}
+	defer sp.close()
+
+	resume := br.saveResumeState()
+	br.browseResults(sp, title)
+
+	if br.listAction == LIST_ACTION_EXIT_ALL {
+		return true
+	}
+
+	br.resume = resume
+	restoreResumeState(br)
+	br.saveRC = false
+	br.listAction = LIST_ACTION_RESUME
+	return true
+}
+
+// fieldSearch narrows a search to the value of one field.
+type fieldSearch struct {
+	name  string
+	col   int
+	delim byte
+}
+
+// value returns the field searched in a line, if the line has it.
+func (f *fieldSearch) value(line []byte) ([]byte, bool) {
+	if f.delim != 0 {
+		fields := splitFields(line, f.delim)
+		if f.col >= len(fields) {
+			return nil, false
+		}
+
+		return []byte(fields[f.col]), true
+	}
+
+	rec, ok := parseRecord(line)
+	if !ok {
+		return nil, false
+	}
+
+	value, ok := rec.values[f.name]
+	return []byte(value), ok
+}
+
+// cutField cuts a pattern at its first = that is not escaped as \=, and
+// turns the escapes that are left into plain = signs. A backslash escapes
+// the character after it, so \\= still ends a field name.
+func cutField(pattern string) (string, string, bool) {
+	var sb strings.Builder
+	name, found := "", false
+
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; {
+
+		case c == '\\' && i+1 < len(pattern) && pattern[i+1] == '=':
+			sb.WriteByte('=')
+			i++
+
+		case c == '\\' && i+1 < len(pattern):
+			sb.WriteString(pattern[i : i+2])
+			i++
+
+		case c == '=' && !found:
+			name, found = sb.String(), true
+			sb.Reset()
+
+		default:
+			sb.WriteByte(c)
+		}
+	}
+
+	if !found {
+		return sb.String(), "", false
+	}
+
+	return name, sb.String(), true
+}
+
+// splitFieldSearch splits a pattern such as level=error, in column view,
+// into the field it searches and the pattern for the value. The name must
+// be a column: a field of the sampled records, or a CSV or TSV column
+// whatever its case. Other patterns, such as user=bob when no record has
+// a user field, search the whole line. An escaped \= searches for an =
+// sign.
+func (br *browseObj) splitFieldSearch(pattern string) (*fieldSearch, string) {
+	if !br.tabular() {
+		return nil, pattern
+	}
+
+	name, expr, ok := cutField(pattern)
+	if !ok {
+		return nil, name
+	}
+
+	whole := name + "=" + expr
+	t := br.table
+
+	if t.records() {
+		col := slices.Index(t.names, name)
+		if col < 0 {
+			return nil, whole
+		}
+
+		return &fieldSearch{name: name, col: col}, expr
+	}
+
+	col := slices.IndexFunc(t.names, func(n string) bool {
+		return strings.EqualFold(strings.TrimSpace(n), name)
+	})
+	if col < 0 {
+		return nil, whole
+	}
+
+	return &fieldSearch{name: name, col: col, delim: t.delim}, expr
+}
+
+// vim: set ts=4 sw=4 noet:
@@ -109,8 +109,8 @@ func (br *browseObj) runInPty(cmdbuf string) {
 	// Restore terminal and reset window size BEFORE waiting for input
 	term.Restore(int(os.Stdout.Fd()), ptySave)
 	pty.InheritSize(os.Stdout, ptmx)
-	br.dispHeight, br.dispWidth, _ = pty.Getsize(ptmx)
-	br.dispRows = br.dispHeight - 1
+	height, width, _ := pty.Getsize(ptmx)
+	br.setScreenSize(width, height)
 
 	// Wait for the input goroutine to finish
 	moveCursor(br.dispHeight, 1, true)
@@ -15,6 +15,16 @@ import (
 
 // scrollDown advances the display by a number of lines toward EOF.
 func (br *browseObj) scrollDown(count int) {
+	if br.repaints() {
+		br.paneScroll(count)
+		return
+	}
+
+	if br.wrapping() {
+		br.wrapScrollDown(count)
+		return
+	}
+
 	br.restoreLast()
 
 	mapSize := br.currentMapSize()
@@ -54,6 +64,22 @@ func (br *browseObj) scrollDown(count int) {
 
 // scrollUp moves the display up by a number of lines toward SOF.
 func (br *browseObj) scrollUp(count int) {
+	if br.repaints() {
+		if br.firstRow <= 0 && br.firstSeg <= 0 {
+			br.modeScroll = MODE_SCROLL_NONE
+			br.restoreLast()
+			return
+		}
+
+		br.paneScroll(-count)
+		return
+	}
+
+	if br.wrapping() {
+		br.wrapScrollUp(count)
+		return
+	}
+
 	br.restoreLast()
 
 	if br.firstRow <= 0 {
@@ -140,6 +166,20 @@ func (br *browseObj) restoreLast() {
 		return
 	}
 
+	if br.repaints() {
+		// the page is painted whole; redraw it
+		br.paintPane(br.firstRow, br.firstSeg)
+		br.restoreCursor()
+		return
+	}
+
+	if br.wrapping() {
+		// wrapped rows do not map to lines; redraw the page
+		br.printWrapPage(br.firstRow, br.firstSeg)
+		br.restoreCursor()
+		return
+	}
+
 	if br.lastRow < br.dispRows {
 		// partial display
 		fmt.Printf(CURPOS+CLEARSCREEN, br.dispRows, 1)
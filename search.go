@@ -12,7 +12,10 @@ package main
 import (
 	"fmt"
 	"io"
+	"path/filepath"
 	"regexp"
+	"strings"
+	"unicode"
 )
 
 // Search formatting and limits.
@@ -55,30 +58,67 @@ func (br *browseObj) searchFile(pattern string, forward, next bool) bool {
 		next = false
 	}
 
-	matchLine, wrapped := br.findSearchMatch(forward, next)
-	if matchLine < 0 {
+	mapSize := br.searchMapSize()
+	if mapSize <= 0 {
+		br.printMessage("Pattern not found", MSG_ORANGE)
+		moveCursor(2, 1, false)
+		return false
+	}
+
+	job := br.startSearch(forward, freshPatternSearch, br.searchStartLine(forward, next, mapSize), mapSize)
+	return br.runSearch(job)
+}
+
+// showSearchResult moves to the line a finished search found.
+func (br *browseObj) showSearchResult(job *searchJob) bool {
+	if job.line < 0 && job.moveOn && !job.cancel.Load() {
+		// the main loop hands the search to the next file
+		br.handoff = &searchHandoff{forward: job.forward}
+		return true
+	}
+
+	if job.line < 0 {
 		br.printMessage("Pattern not found", MSG_ORANGE)
 		moveCursor(2, 1, false)
 		return false
 	}
 
-	if wrapped {
-		br.displayWrapMessage(forward)
+	// a search that ran in the background stops follow and tail
+	br.modeScroll = MODE_SCROLL_NONE
+
+	if job.wrapped {
+		br.displayWrapMessage(job.forward)
+	} else if job.entered {
+		br.timedMessage("Found in "+filepath.Base(br.sourceName()), MSG_GREEN)
 	}
 
-	br.lastMatch = matchLine
-	displayTop := br.searchDisplayTop(matchLine, forward)
-	if freshPatternSearch && !wrapped && br.lineOnCurrentPage(matchLine) {
+	br.lastMatch = job.line
+	if br.dirList != nil {
+		// select the matching entry
+		br.dirCursor = job.line
+	}
+	displayTop := br.searchDisplayTop(job.line, job.forward)
+	if job.fresh && !job.wrapped && br.lineOnCurrentPage(job.line) {
 		displayTop = br.firstRow
 	}
 	br.printPage(displayTop)
+	br.reportMatch(job.line)
 
 	return true
 }
 
 // lineOnCurrentPage reports whether a line is visible before search repositions.
 func (br *browseObj) lineOnCurrentPage(lineNum int) bool {
-	return lineNum >= br.firstRow && lineNum < br.firstRow+br.dispRows
+	return lineNum >= br.firstRow && lineNum < br.pageEnd()
+}
+
+// pageEnd returns the line after the last one on the current page.
+func (br *browseObj) pageEnd() int {
+	if br.wrapping() {
+		return br.lastRow
+	}
+
+	return br.firstRow + br.dispRows
 }
 
 // searchMapSize returns a stable snapshot of the currently mapped line count.
@@ -98,22 +138,17 @@ func (br *browseObj) displayWrapMessage(forward bool) {
 	}
 }
 
-// findSearchMatch returns the next matching line without changing display state.
-func (br *browseObj) findSearchMatch(forward, next bool) (int, bool) {
-	mapSize := br.searchMapSize()
-	if mapSize <= 0 {
-		return -1, false
-	}
-
-	startLine := br.searchStartLine(forward, next, mapSize)
-
-	if forward {
-		if matchLine := br.findForwardMatch(startLine, mapSize, mapSize); matchLine >= 0 {
+// findSearchMatch returns the next matching line from startLine, wrapping
+// once around the file unless the search moves on to another file, without
+// changing display state. It runs in the search job's goroutine.
+func (br *browseObj) findSearchMatch(job *searchJob, startLine, mapSize int) (int, bool) {
+	if job.forward {
+		if matchLine := br.findForwardMatch(job, startLine, mapSize, mapSize); matchLine >= 0 {
 			return matchLine, false
 		}
 
-		if startLine > 0 {
-			if matchLine := br.findForwardMatch(0, minimum(startLine, mapSize), mapSize); matchLine >= 0 {
+		if startLine > 0 && !job.moveOn {
+			if matchLine := br.findForwardMatch(job, 0, minimum(startLine, mapSize), mapSize); matchLine >= 0 {
 				return matchLine, true
 			}
 		}
@@ -121,12 +156,12 @@ func (br *browseObj) findSearchMatch(forward, next bool) (int, bool) {
 		return -1, false
 	}
 
-	if matchLine := br.findReverseMatch(startLine, 0, mapSize); matchLine >= 0 {
+	if matchLine := br.findReverseMatch(job, startLine, 0, mapSize); matchLine >= 0 {
 		return matchLine, false
 	}
 
-	if startLine < mapSize-1 {
-		if matchLine := br.findReverseMatch(mapSize-1, maximum(startLine+1, 0), mapSize); matchLine >= 0 {
+	if startLine < mapSize-1 && !job.moveOn {
+		if matchLine := br.findReverseMatch(job, mapSize-1, maximum(startLine+1, 0), mapSize); matchLine >= 0 {
 			return matchLine, true
 		}
 	}
@@ -145,7 +180,7 @@ func (br *browseObj) searchStartLine(forward, next bool, mapSize int) int {
 	}
 
 	if forward {
-		return br.firstRow + br.dispRows
+		return br.pageEnd()
 	}
 
 	return br.firstRow - 1
@@ -157,12 +192,12 @@ func (br *browseObj) currentPageSearchStart(forward bool, mapSize int) int {
 		return br.firstRow
 	}
 
-	return minimum(br.firstRow+br.dispRows-1, mapSize-1)
+	return minimum(br.pageEnd()-1, mapSize-1)
 }
 
 // currentPageHasMatch reports whether the visible page already shows a match.
 func (br *browseObj) currentPageHasMatch(mapSize int) bool {
-	pageEnd := minimum(br.firstRow+br.dispRows, mapSize)
+	pageEnd := minimum(br.pageEnd(), mapSize)
 
 	for lineNum := br.firstRow; lineNum < pageEnd; lineNum++ {
 		if br.lineIsMatch(lineNum) {
@@ -174,12 +209,17 @@ func (br *browseObj) currentPageHasMatch(mapSize int) bool {
 }
 
 // findForwardMatch scans from startLine up to endLine for the first match.
-func (br *browseObj) findForwardMatch(startLine, endLine, mapSize int) int {
+func (br *browseObj) findForwardMatch(job *searchJob, startLine, endLine, mapSize int) int {
 	startLine = maximum(startLine, 0)
 	endLine = minimum(endLine, mapSize)
 
-	for lineNum := startLine; lineNum < endLine; lineNum++ {
-		if br.lineIsMatch(lineNum) {
+	if br.modeHex {
+		return br.hexFindForward(job, startLine, endLine)
+	}
+
+	for lineNum := startLine; lineNum < endLine && !job.cancel.Load(); lineNum++ {
+		job.scanned.Add(1)
+		if br.matchLine(lineNum, &job.scratch) {
 			return lineNum
 		}
 	}
@@ -188,12 +228,17 @@ func (br *browseObj) findForwardMatch(startLine, endLine, mapSize int) int {
 }
 
 // findReverseMatch scans from startLine down to endLine for the first match.
-func (br *browseObj) findReverseMatch(startLine, endLine, mapSize int) int {
+func (br *browseObj) findReverseMatch(job *searchJob, startLine, endLine, mapSize int) int {
 	startLine = minimum(startLine, mapSize-1)
 	endLine = maximum(endLine, 0)
 
-	for lineNum := startLine; lineNum >= endLine; lineNum-- {
-		if br.lineIsMatch(lineNum) {
+	if br.modeHex {
+		return br.hexFindReverse(job, startLine, endLine)
+	}
+
+	for lineNum := startLine; lineNum >= endLine && !job.cancel.Load(); lineNum-- {
+		job.scanned.Add(1)
+		if br.matchLine(lineNum, &job.scratch) {
 			return lineNum
 		}
 	}
@@ -203,6 +248,14 @@ func (br *browseObj) findReverseMatch(startLine, endLine, mapSize int) int {
 
 // searchDisplayTop positions the match with directional context.
 func (br *browseObj) searchDisplayTop(matchLine int, forward bool) int {
+	if br.wrapping() {
+		if forward {
+			return br.wrapDisplayTop(matchLine, br.dispRows/6)
+		}
+
+		return br.wrapDisplayTop(matchLine, (br.dispRows*5)/6)
+	}
+
 	if forward {
 		return matchLine - br.dispRows/6
 	}
@@ -215,29 +268,47 @@ func (br *browseObj) searchDisplayTop(matchLine int, forward bool) int {
 // only safe to call from the main goroutine (search/rendering), never the
 // reader. The buffer contents are valid only until the next call.
 func (br *browseObj) lineIsMatch(lineno int) bool {
+	return br.matchLine(lineno, &br.matchScratch)
+}
+
+// matchLine reports whether a line matches the active pattern, reading it
+// into a scratch buffer owned by the caller's goroutine.
+func (br *browseObj) matchLine(lineno int, scratch *[]byte) bool {
+	if br.modeHex {
+		return br.hexIsMatch(lineno)
+	}
+
 	if br.re == nil {
 		return false
 	}
 
+	return br.lineMatches(br.re, lineno, scratch)
+}
+
+// lineMatches reports whether a text line matches re. It is safe to call
+// from any goroutine that owns scratch.
+func (br *browseObj) lineMatches(re *regexp.Regexp, lineno int, scratch *[]byte) bool {
 	br.mutex.Lock()
 	if lineno >= br.mapSiz || br.fp == nil {
 		br.mutex.Unlock()
 		return false
 	}
 
-	seek := br.seekMap[lineno]
-	size := br.sizeMap[lineno]
+	seek, size := br.lineExtent(lineno)
+	encoding := br.encoding
+	ctrlMode := br.ctrlMode
+	field := br.field
 
 	// Make sure size is reasonable to avoid panics (16MB)
-	if size < 0 || size > (16<<20) {
+	if size < 0 || size > MAXLINESIZ {
 		br.mutex.Unlock()
 		return false
 	}
 
-	if int64(cap(br.matchScratch)) < size {
-		br.matchScratch = make([]byte, size)
+	if int64(cap(*scratch)) < size {
+		*scratch = make([]byte, size)
 	}
-	buf := br.matchScratch[:size]
+	buf := (*scratch)[:size]
 
 	n, err := br.fp.ReadAt(buf, seek)
 	br.mutex.Unlock()
@@ -245,29 +316,37 @@ func (br *browseObj) lineIsMatch(lineno int) bool {
 		return false
 	}
 
-	// expandTabs returns buf[:n] unchanged when there are no tabs, so the
-	// common case stays allocation-free.
-	return br.re.Match(expandTabs(buf[:n]))
+	// decodeLine and visibleText return buf[:n] unchanged for UTF-8 without
+	// tabs or controls, so the common case stays allocation-free.
+	line := visibleLine(buf[:n], seek, encoding, ctrlMode)
+	if field == nil {
+		return re.Match(line)
+	}
+
+	value, ok := field.value(line)
+	return ok && re.Match(value)
 }
 
-// replaceMatch highlights matches in a line and formats it for display.
-func (br *browseObj) replaceMatch(lineno int, input []byte) string {
-	sol := max(br.shiftWidth, 0)
+// replaceMatch highlights matches in a line and formats it for display,
+// with the colors of its spans.
+func (br *browseObj) replaceMatch(lineno int, input []byte, spans []sgrSpan) string {
+	shift := max(br.shiftWidth, 0)
 
-	// Slice safely
-	var content []byte
+	// shiftWidth counts columns; a wide character cut by the left edge
+	// leaves a blank
+	sol, pad := columnIndex(input, shift)
+	content := input[sol:]
+	indent := strings.Repeat(" ", pad)
 
-	if sol < len(input) {
-		content = input[sol:]
-	} else {
-		content = nil
-	}
+	if br.re == nil && len(br.pinned) == 0 {
+		if spans != nil && len(content) > 0 {
+			return br.formatLine(lineno, indent+br.paintSegment(input, sol, len(input), nil, "", spans))
+		}
 
-	if br.re == nil {
-		return br.formatLine(lineno, string(content))
+		return br.formatLine(lineno, indent+string(content))
 	}
 
-	leftMatch, rightMatch := br.undisplayedMatches(input, sol)
+	leftMatch, rightMatch := br.undisplayedMatches(lineno, input, shift)
 
 	if len(content) == 0 {
 		if leftMatch {
@@ -278,17 +357,18 @@ func (br *browseObj) replaceMatch(lineno int, input []byte) string {
 		return br.formatLine(lineno, "")
 	}
 
-	var replaced []byte
-
+	// text is tinted when search matches are shifted out of view
+	base := ""
 	if leftMatch || rightMatch {
-		replaced = br.re.ReplaceAll(content, []byte(br.replace+_VID_GREEN_FG))
-		replaced = append([]byte(_VID_GREEN_FG), replaced...)
-		replaced = append(replaced, []byte(VIDOFF)...)
-	} else {
-		replaced = br.re.ReplaceAll(content, []byte(br.replace))
+		base = _VID_GREEN_FG
+	}
+
+	replaced := indent + br.paintSegment(input, sol, len(input), br.matchPaint(lineno, input), base, spans)
+	if base != "" {
+		replaced += VIDOFF
 	}
 
-	return br.formatLine(lineno, string(replaced))
+	return br.formatLine(lineno, replaced)
 }
 
 // formatLine formats a line with optional line numbers.
@@ -297,7 +377,7 @@ func (br *browseObj) formatLine(lineno int, content string) string {
 
 	if br.modeNumbers {
 		// dim attribute is optional in the ANSI spec
-		return fmt.Sprintf("%s%6d%s %s", _VID_DIM, lineno, _VID_OFF, content)
+		return fmt.Sprintf("%s%6d%s %s", _VID_DIM, br.origLine(lineno), _VID_OFF, content)
 	}
 
 	return content
@@ -307,8 +387,17 @@ func (br *browseObj) formatLine(lineno int, content string) string {
 func (br *browseObj) doSearch(oldDir, newDir bool) bool {
 	moveCursor(br.dispRows, 1, true)
 
-	pattern, cancelled := userSearchComp(newDir)
+	var typed func(text, beforeCursor string)
+	preview := br.newSearchPreview(newDir, searchPrompt(newDir))
+	if preview != nil {
+		typed = func(text, beforeCursor string) {
+			br.previewSearch(preview, text, beforeCursor)
+		}
+	}
+
+	pattern, cancelled := userSearchComp(newDir, typed)
 	br.shownMsg = true
+	br.endSearchPreview(preview, cancelled)
 
 	if cancelled {
 		br.restoreLast()
@@ -371,41 +460,112 @@ func (br *browseObj) reCompile(pattern string) (int, error) {
 		return 0, nil
 	}
 
-	compilePattern := pattern
-
-	if br.searchFixed {
-		compilePattern = regexp.QuoteMeta(compilePattern)
-	}
-
-	if br.ignoreCase {
-		compilePattern = "(?i)" + compilePattern
-	}
+	// in column view, name=pattern searches one field
+	field, expr := br.splitFieldSearch(pattern)
 
-	re, err := regexp.Compile(compilePattern)
+	re, err := br.compileSearch(expr)
 	if err != nil {
 		return 0, err
 	}
 
 	br.pattern = pattern
 	br.re = re
-	br.replace = fmt.Sprintf("%s%s%s", MSG_GREEN, "$0", VIDOFF)
+	br.setSearchField(field)
+	br.hexNeedle = parseHexPattern(pattern)
 
 	return len(pattern), nil
 }
 
-// undisplayedMatches reports whether matches exist outside the visible slice.
-func (br *browseObj) undisplayedMatches(input []byte, sol int) (bool, bool) {
+// setSearchField sets the field searches are narrowed to. Searches read it
+// in the background.
+func (br *browseObj) setSearchField(field *fieldSearch) {
+	br.mutex.Lock()
+	br.field = field
+	br.mutex.Unlock()
+}
+
+// compileSearch compiles a pattern with the current search modes.
+func (br *browseObj) compileSearch(pattern string) (*regexp.Regexp, error) {
+	return compilePattern(pattern, br.caseless(pattern), br.searchFixed, br.wholeWord)
+}
+
+// caseless reports whether a search for pattern ignores case. In smart-case
+// mode it does unless the pattern has an uppercase letter; escapes such as
+// \S in a regex do not count.
+func (br *browseObj) caseless(pattern string) bool {
+	if !br.smartCase {
+		return br.ignoreCase
+	}
+
+	escaped := false
+	for _, r := range pattern {
+		switch {
+
+		case escaped:
+			escaped = false
+
+		case r == '\\' && !br.searchFixed:
+			escaped = true
+
+		case unicode.IsUpper(r):
+			return false
+		}
+	}
+
+	return true
+}
+
+// searchFlags returns the command line flags that give a child browse the
+// current search modes.
+func (br *browseObj) searchFlags() string {
+	var flags []string
+
+	if br.ignoreCase {
+		flags = append(flags, "-i")
+	}
+	if br.searchFixed {
+		flags = append(flags, "-I")
+	}
+	if br.smartCase {
+		flags = append(flags, "-s")
+	}
+	if br.wholeWord {
+		flags = append(flags, "-W")
+	}
+
+	return strings.Join(flags, " ")
+}
+
+// searchMatches returns where the active pattern matches a displayed line.
+// In column view the fields are matched, and a search narrowed to a field
+// matches only in the field's column.
+func (br *browseObj) searchMatches(lineno int, input []byte) [][]int {
+	if !br.tabular() {
+		return br.re.FindAllIndex(input, -1)
+	}
+
+	matches, ok := br.tableMatches(lineno, br.re, true)
+	if !ok && br.field == nil {
+		// a line that is not a record is shown as it is
+		return br.re.FindAllIndex(input, -1)
+	}
+
+	return matches
+}
+
+// undisplayedMatches reports whether matches exist outside the visible
+// columns, left of shift or past the right edge.
+func (br *browseObj) undisplayedMatches(lineno int, input []byte, shift int) (bool, bool) {
 	if br.re == nil {
 		return false, false
 	}
 
-	// Bounds check for sol parameter
-	if sol < 0 {
-		sol = 0
+	// Bounds check for shift parameter
+	if shift < 0 {
+		shift = 0
 	}
 
-	// Use FindAllIndex for efficiency
-	matches := br.re.FindAllIndex(input, -1)
+	matches := br.searchMatches(lineno, input)
 	if len(matches) == 0 {
 		return false, false
 	}
@@ -421,6 +581,7 @@ func (br *browseObj) undisplayedMatches(input []byte, sol int) (bool, bool) {
 	}
 
 	leftMatch, rightMatch := false, false
+	cols := columnCounter{text: input}
 
 	for _, index := range matches {
 		// Ensure index has at least 2 elements (start and end positions)
@@ -433,12 +594,12 @@ func (br *browseObj) undisplayedMatches(input []byte, sol int) (bool, bool) {
 			continue
 		}
 
-		if !leftMatch && index[0] < sol {
+		if !leftMatch && cols.column(index[0]) < shift {
 			leftMatch = true
 		}
 
 		// Calculate right boundary with safety checks
-		rightBoundary := index[1] - sol + 2
+		rightBoundary := cols.column(index[1]) - shift + 2
 		if !rightMatch && rightBoundary > displayWidth {
 			// NB: off by two
 			rightMatch = true
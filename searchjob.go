@@ -0,0 +1,199 @@
+// searchjob.go
+// run searches in the background with progress and cancel
+//
+// Copyright (c) 2024-2026 jjb
+// All rights reserved.
+//
+// This source code is licensed under the MIT license found
+// in the root directory of this source tree.
+
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+)
+
+// Background search timing and keys.
+const (
+	// searches that finish sooner show no progress
+	SEARCH_WAIT = 200 * time.Millisecond
+
+	// keys that cancel a running search
+	SEARCH_CANCEL   = '\003'
+	SEARCH_CANCEL_1 = '\033'
+)
+
+// searchJob is a search scanning the file in its own goroutine. The main
+// loop polls it between keystrokes, so scroll modes, follow updates and
+// redraws carry on while it runs.
+type searchJob struct {
+	forward bool
+	fresh   bool
+	moveOn  bool
+	entered bool
+	total   int
+	scanned atomic.Int64
+	cancel  atomic.Bool
+	done    chan struct{}
+	scratch []byte
+
+	// a grep writes every match here instead of stopping at the first
+	grep *grepResults
+
+	// set before done is closed
+	line    int
+	wrapped bool
+}
+
+// startSearch starts scanning mapSize lines from startLine. With -S a
+// search that would wrap moves on to the next file in the list instead,
+// or the previous one in reverse.
+func (br *browseObj) startSearch(forward, fresh bool, startLine, mapSize int) *searchJob {
+	job := &searchJob{
+		forward: forward,
+		fresh:   fresh,
+		total:   mapSize,
+		done:    make(chan struct{}),
+	}
+
+	if br.searchList {
+		job.moveOn = (forward && len(br.currentList) > 1) || (!forward && !br.listAtStart)
+	}
+
+	go func() {
+		job.line, job.wrapped = br.findSearchMatch(job, startLine, mapSize)
+		close(job.done)
+	}()
+
+	return job
+}
+
+// runSearch shows the result of a started search at once when it is
+// short, and leaves it to the main loop otherwise.
+func (br *browseObj) runSearch(job *searchJob) bool {
+	// short searches finish before a progress message would flicker
+	select {
+	case <-job.done:
+		return br.showSearchResult(job)
+
+	case <-time.After(SEARCH_WAIT):
+		br.search = job
+		br.searchProgress(job)
+		return true
+	}
+}
+
+// searchHandoff carries a search into the next or previous file. A
+// forward search that finds no file it can open returns to the one it
+// left, at the place it was left, to report that the pattern was not
+// found.
+type searchHandoff struct {
+	forward  bool
+	notFound bool
+	left     browseResumeState
+}
+
+// leaveForSearch ends browsing of the current file so processFileList
+// opens the file the search moves on to.
+func (br *browseObj) leaveForSearch() {
+	br.saveRC = true
+	br.exit = false
+	br.handoff.left = br.saveResumeState()
+
+	if !br.handoff.forward {
+		br.listAction = LIST_ACTION_PREV
+	}
+}
+
+// resumeSearch continues a handed-off search from the start of the file,
+// or from its end in reverse. It returns the search direction.
+func (br *browseObj) resumeSearch() bool {
+	forward := br.handoff.forward
+	notFound := br.handoff.notFound
+	br.handoff = nil
+
+	if notFound {
+		br.printMessage("Pattern not found", MSG_ORANGE)
+		return forward
+	}
+
+	mapSize := br.searchMapSize()
+	if mapSize <= 0 {
+		return forward
+	}
+
+	startLine := 0
+	if !forward {
+		startLine = mapSize - 1
+	}
+
+	br.printMessage("Searching "+filepath.Base(br.sourceName()), MSG_GREEN)
+
+	job := br.startSearch(forward, false, startLine, mapSize)
+	job.entered = true
+	br.runSearch(job)
+
+	return forward
+}
+
+// pollSearch shows the result of the running search once it finishes, or
+// its progress until then. It returns true when a grep has shown its
+// results and the file must be reopened.
+func (br *browseObj) pollSearch() bool {
+	job := br.search
+
+	select {
+	case <-job.done:
+		br.search = nil
+		if job.grep != nil {
+			return br.showGrepResults(job)
+		}
+		br.showSearchResult(job)
+
+	default:
+		br.searchProgress(job)
+	}
+
+	return false
+}
+
+// searchProgress shows how much of the file the search has scanned.
+func (br *browseObj) searchProgress(job *searchJob) {
+	percent := 0
+	if job.total > 0 {
+		percent = int(min(job.scanned.Load()*100/int64(job.total), 99))
+	}
+
+	br.printMessage(fmt.Sprintf("Searching %d%% ... Ctrl+C cancels", percent), MSG_GREEN)
+}
+
+// cancelSearch stops the running search and leaves the view as it was.
+func (br *browseObj) cancelSearch() {
+	job := br.search
+	job.cancel.Store(true)
+	<-job.done
+	br.search = nil
+
+	if job.grep != nil {
+		job.grep.sp.close()
+	}
+
+	br.printMessage("Search cancelled", MSG_ORANGE)
+}
+
+// searchKey handles a key pressed while a search runs. Keys other than
+// the cancel keys are ignored until the search finishes. It returns true
+// as pollSearch does.
+func (br *browseObj) searchKey(key []byte) bool {
+	if len(key) == 1 && (key[0] == SEARCH_CANCEL || key[0] == SEARCH_CANCEL_1) {
+		br.cancelSearch()
+		return false
+	}
+
+	return br.pollSearch()
+}
+
+// vim: set ts=4 sw=4 noet:
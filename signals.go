@@ -35,12 +35,14 @@ func (br *browseObj) saneExit() {
 	ttyRestore()
 	resetScrRegion()
 	fmt.Print(LINEWRAPON + SGR0)
-	moveCursor(br.dispHeight, 1, true)
+	fmt.Printf(CURPOS+CLEARLINE, br.termHeight, 1)
 
 	if br.fromStdin {
 		os.Remove(br.fileName)
 	}
 
+	removeSpools()
+
 	if !br.fromStdin && br.saveRC {
 		br.writeRcFile()
 	}
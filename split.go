@@ -0,0 +1,691 @@
+// split.go
+// split screen with two panes
+//
+// Copyright (c) 2024-2026 jjb
+// All rights reserved.
+//
+// This source code is licensed under the MIT license found
+// in the root directory of this source tree.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// Smallest panes, header included.
+const (
+	SPLIT_MIN_ROWS = 4
+	SPLIT_MIN_COLS = 20
+)
+
+// originRow and originCol place the focused pane on the screen; moveCursor
+// positions are relative to them. originWidth is the pane width when the
+// panes sit side by side and a line cannot be cleared to the screen edge.
+var originRow, originCol, originWidth int
+
+// promptShown is set when a prompt may have run across the pane without
+// the focus, which is then repainted.
+var promptShown bool
+
+// splitView holds two panes on one screen. Each pane runs its own command
+// loop and reader; the loops take turns, and only the focused one reads
+// keys.
+type splitView struct {
+	panes    [2]*browseObj
+	turn     [2]chan bool
+	focus    int
+	vertical bool
+	size     int
+	locked   bool
+	lockRow  int
+	closing  bool
+
+	// what each pane showed when it was last painted
+	shown [2]paneState
+}
+
+// paneState is what a pane shows: its file, line map, position and size.
+type paneState struct {
+	fileSeq  uint64
+	mapSeq   uint64
+	mapSize  int
+	firstRow int
+	firstSeg int
+	width    int
+	rows     int
+}
+
+// paneState returns what the pane shows now.
+func (br *browseObj) paneState() paneState {
+	br.mutex.Lock()
+	defer br.mutex.Unlock()
+
+	return paneState{
+		fileSeq:  br.fileSeq,
+		mapSeq:   br.mapSeq,
+		mapSize:  br.mapSiz,
+		firstRow: br.firstRow,
+		firstSeg: br.firstSeg,
+		width:    br.dispWidth,
+		rows:     br.dispRows,
+	}
+}
+
+// index returns the pane number of br.
+func (s *splitView) index(br *browseObj) int {
+	if s.panes[1] == br {
+		return 1
+	}
+
+	return 0
+}
+
+// layout divides the screen between the panes. size is the height or width
+// of the first pane; zero splits the screen evenly.
+func (s *splitView) layout(width, rows int) {
+	p0, p1 := s.panes[0], s.panes[1]
+
+	if s.vertical {
+		// one column for the divider
+		if s.size == 0 {
+			s.size = (width - 1) / 2
+		}
+		s.size = min(max(s.size, SPLIT_MIN_COLS), width-1-SPLIT_MIN_COLS)
+
+		p0.setPane(0, 0, s.size, rows)
+		p1.setPane(0, s.size+1, width-s.size-1, rows)
+	} else {
+		if s.size == 0 {
+			s.size = rows / 2
+		}
+		s.size = min(max(s.size, SPLIT_MIN_ROWS), rows-SPLIT_MIN_ROWS)
+
+		p0.setPane(0, 0, width, s.size)
+		p1.setPane(s.size, 0, width, rows-s.size)
+	}
+
+	s.setOrigin()
+}
+
+// setOrigin points moveCursor at the focused pane.
+func (s *splitView) setOrigin() {
+	br := s.panes[s.focus]
+
+	originRow, originCol = br.paneTop, br.paneLeft
+	originWidth = 0
+	if s.vertical {
+		originWidth = br.dispWidth
+	}
+}
+
+// setPane places the page on the screen. height counts the header, and
+// rows frozen under it are taken from the top of the page.
+func (br *browseObj) setPane(top, left, width, height int) {
+	frozen := br.frozenRows()
+
+	br.paneTop = top + frozen
+	br.paneLeft = left
+	br.dispWidth = width
+	br.dispHeight = height - frozen
+	br.dispRows = height - frozen - 1
+}
+
+// newPane returns a browseObj for the second pane, with the display and
+// search settings of this one.
+func (br *browseObj) newPane() *browseObj {
+	return &browseObj{
+		tty:          br.tty,
+		termWidth:    br.termWidth,
+		termHeight:   br.termHeight,
+		encodingOpt:  br.encodingOpt,
+		pattern:      br.pattern,
+		pinned:       slices.Clone(br.pinned),
+		ignoreCase:   br.ignoreCase,
+		searchFixed:  br.searchFixed,
+		smartCase:    br.smartCase,
+		wholeWord:    br.wholeWord,
+		lastMatch:    SEARCH_RESET,
+		searchList:   br.searchList,
+		modeNumbers:  br.modeNumbers,
+		modeWrap:     br.modeWrap,
+		modeSyntax:   br.modeSyntax,
+		ctrlMode:     br.ctrlMode,
+		logStyle:     br.logStyle,
+		modeStatus:   br.modeStatus,
+		statusFields: br.statusFields,
+	}
+}
+
+// splitFits reports whether the screen has room for two panes.
+func (br *browseObj) splitFits(vertical bool) bool {
+	if vertical {
+		return br.termWidth >= 2*SPLIT_MIN_COLS+1
+	}
+
+	rows := br.termHeight
+	if br.modeStatus {
+		rows--
+	}
+
+	return rows >= 2*SPLIT_MIN_ROWS
+}
+
+// openSplit prompts for files to browse in a second pane; an empty answer
+// shows this file again from the same place. The new pane takes the focus.
+// It returns true when browsing should stop, as switchPane does.
+func (br *browseObj) openSplit(vertical bool) bool {
+	if !br.splitFits(vertical) {
+		br.printMessage("The screen is too small to split", MSG_ORANGE)
+		return false
+	}
+
+	moveCursor(br.dispRows, 1, true)
+
+	lbuf, cancelled := userFileComp()
+	if cancelled {
+		br.pageCurrent()
+		return false
+	}
+
+	pane := br.newPane()
+
+	var files []string
+	if input := strings.TrimSpace(lbuf); input != "" {
+		if files = br.fileArgs(input); len(files) == 0 {
+			br.pageCurrent()
+			return false
+		}
+	} else {
+		// the pane has no filter, so it starts at the file line
+		pane.initTitle = br.title
+		pane.firstRow = br.origLine(br.firstRow)
+		pane.shiftWidth = br.shiftWidth
+		pane.dirCursor = br.dirCursor
+		if br.filter == nil {
+			pane.marks = br.marks
+		}
+
+		if br.fromStdin {
+			// the pane learns of the end of the input with this one
+			br.stdin.join(pane)
+		} else {
+			files = []string{br.absFileName}
+			if br.absFileName == "" {
+				files[0] = br.fileName
+			}
+		}
+	}
+
+	s := &splitView{
+		panes:    [2]*browseObj{br, pane},
+		turn:     [2]chan bool{make(chan bool), make(chan bool)},
+		vertical: vertical,
+	}
+	br.split, pane.split = s, s
+	br.setScreenSize(br.termWidth, br.termHeight)
+
+	go s.run(files)
+
+	return br.switchPane()
+}
+
+// run browses files in the second pane, or piped input again when files
+// is nil, then closes the split.
+func (s *splitView) run(files []string) {
+	pane := s.panes[1]
+
+	<-s.turn[1]
+	s.takeFocus(pane)
+
+	if files == nil {
+		if fp, err := os.Open(s.panes[0].fileName); err == nil {
+			browseFile(pane, fp, fp.Name(), pane.initTitle, true)
+			fp.Close()
+		}
+	} else {
+		// toplevel keeps the position set by openSplit
+		processFileList(pane, files, true)
+	}
+
+	s.close()
+}
+
+// switchPane hands the keyboard to the other pane and waits for it to come
+// back. It returns true when browsing should stop.
+func (br *browseObj) switchPane() bool {
+	s := br.split
+	if s == nil {
+		br.printMessage("The screen is not split", MSG_ORANGE)
+		return false
+	}
+
+	me := s.index(br)
+	s.turn[1-me] <- true
+
+	return br.awaitTurn(s, me)
+}
+
+// awaitTurn waits for the focus, then redraws the pane. It returns true
+// when browsing should stop: the split was closed from the first pane,
+// or the second pane exited all lists.
+func (br *browseObj) awaitTurn(s *splitView, me int) bool {
+	if !<-s.turn[me] {
+		// the second pane closes with the split
+		br.saveRC = false
+		br.exit = true
+		br.listAction = LIST_ACTION_EXIT_ALL
+		return true
+	}
+
+	if br.listAction == LIST_ACTION_EXIT_ALL {
+		return true
+	}
+
+	if br.split != nil {
+		s.takeFocus(br)
+	} else {
+		br.catchSignals()
+	}
+
+	br.pageHeader()
+	br.pageCurrent()
+
+	if br.inMotion() {
+		fmt.Print(CURRESTORE)
+	}
+
+	return false
+}
+
+// takeFocus gives br the keyboard, the cursor and the resize signal.
+func (s *splitView) takeFocus(br *browseObj) {
+	s.focus = s.index(br)
+	s.lockRow = br.firstRow
+	s.setOrigin()
+	br.catchSignals()
+}
+
+// close ends the split when the second pane is done, and gives the whole
+// screen back to the first pane.
+func (s *splitView) close() {
+	p0, p1 := s.panes[0], s.panes[1]
+
+	// retire the reader with its pane
+	p1.mutex.Lock()
+	p1.fileSeq++
+	p1.mutex.Unlock()
+	p1.wakeReader()
+
+	if p1.listAction == LIST_ACTION_EXIT_ALL && !s.closing {
+		// exit all lists leaves browse from either pane
+		p0.saveRC = p1.saveRC
+		p0.exit = true
+		p0.listAction = LIST_ACTION_EXIT_ALL
+	}
+
+	p0.split, p1.split = nil, nil
+	p0.setScreenSize(p0.termWidth, p0.termHeight)
+
+	s.turn[0] <- true
+}
+
+// closeSplit closes the second pane. It returns true when browsing should
+// stop, as it does in the second pane itself.
+func (br *browseObj) closeSplit() bool {
+	s := br.split
+	s.closing = true
+
+	if br.isSecondPane() {
+		br.saveRC = false
+		br.exit = true
+		br.listAction = LIST_ACTION_EXIT_ALL
+		return true
+	}
+
+	s.turn[1] <- false
+
+	return br.awaitTurn(s, 0)
+}
+
+// splitCommand opens a split, closes it when it already has this
+// orientation, or turns it to this orientation.
+func (br *browseObj) splitCommand(vertical bool) bool {
+	s := br.split
+	if s == nil {
+		return br.openSplit(vertical)
+	}
+
+	if s.vertical == vertical {
+		return br.closeSplit()
+	}
+
+	if !br.splitFits(vertical) {
+		br.printMessage("The screen is too small to split", MSG_ORANGE)
+		return false
+	}
+
+	s.vertical = vertical
+	s.size = 0
+	br.setScreenSize(br.termWidth, br.termHeight)
+	br.pageHeader()
+	br.pageCurrent()
+
+	return false
+}
+
+// resizePane grows the focused pane by delta rows, or by delta tab stops
+// side by side.
+func (br *browseObj) resizePane(delta int) {
+	s := br.split
+	if s == nil {
+		br.printMessage("The screen is not split", MSG_ORANGE)
+		return
+	}
+
+	if s.vertical {
+		delta *= TABWIDTH
+	}
+
+	// size belongs to the first pane
+	if br.isSecondPane() {
+		delta = -delta
+	}
+
+	s.size += delta
+	br.setScreenSize(br.termWidth, br.termHeight)
+	br.pageHeader()
+	br.pageCurrent()
+}
+
+// toggleScrollLock makes the other pane follow this one line for line.
+func (br *browseObj) toggleScrollLock() {
+	s := br.split
+	if s == nil {
+		br.printMessage("The screen is not split", MSG_ORANGE)
+		return
+	}
+
+	s.locked = !s.locked
+	s.lockRow = br.firstRow
+
+	if s.locked {
+		br.printMessage("Panes scroll together", MSG_GREEN)
+	} else {
+		br.printMessage("Panes scroll on their own", MSG_GREEN)
+	}
+}
+
+// hasFocus reports whether br reads the keys.
+func (br *browseObj) hasFocus() bool {
+	return br.split == nil || br.split.panes[br.split.focus] == br
+}
+
+// isSecondPane reports whether br is the pane a split added.
+func (br *browseObj) isSecondPane() bool {
+	return br.split != nil && br.split.panes[1] == br
+}
+
+// drawFrame clears the screen and draws the pane headers, the divider and
+// the pane without the focus. The focused pane draws itself.
+func (s *splitView) drawFrame() {
+	const minHeaderWidth = 10
+
+	focused := s.panes[s.focus]
+
+	var sb strings.Builder
+
+	sb.WriteString(fmt.Sprintf(CURPOS, 1, 1))
+	sb.WriteString(CLEARSCREEN)
+	sb.WriteString(LINEWRAPOFF)
+	sb.WriteString(fmt.Sprintf(SCROLLREGION, focused.paneTop+2, focused.paneTop+focused.dispHeight))
+
+	for i, pane := range s.panes {
+		if pane.dispWidth < minHeaderWidth {
+			continue
+		}
+
+		// the focused header stands out
+		attr := _VID_REV
+		if i == s.focus {
+			attr = VIDBOLDREV
+		}
+
+		sb.WriteString(fmt.Sprintf(CURPOS, pane.paneTop-pane.frozenRows()+1, pane.paneLeft+1))
+		sb.WriteString(headerBar(pane.title, pane.dispWidth, attr))
+	}
+
+	if s.vertical {
+		sb.WriteString(fmt.Sprintf(CURPOS, 1, s.panes[1].paneLeft))
+		sb.WriteString(ENTERGRAPHICS + VERTLINE + EXITGRAPHICS)
+	}
+
+	os.Stdout.WriteString(sb.String())
+
+	// the screen was cleared under the status line
+	focused.statusShown = ""
+
+	other := s.panes[1-s.focus]
+	other.paintPane(other.firstRow, other.firstSeg)
+}
+
+// repaints reports whether pages are painted whole instead of scrolled: a
+// pane cannot scroll the terminal, and a column view keeps its header.
+func (br *browseObj) repaints() bool {
+	return br.split != nil || br.tabular()
+}
+
+// paintPane draws a page in the pane starting at a position. Rows are
+// written in place, clipped and padded to the pane width, so the other
+// pane is left alone.
+func (br *browseObj) paintPane(line, seg int) {
+	if br.dispWidth <= 0 || br.dispRows <= 0 {
+		return
+	}
+
+	sof := " " + VIDBLINK + "SOF" + VIDOFF
+	eof := " " + VIDBLINK + "EOF" + VIDOFF
+
+	mapSize := br.currentMapSize()
+	rows := make([]string, 0, br.dispRows)
+	eofRow := -1
+
+	if br.wrapping() {
+		line, seg = br.wrapClampTop(line, seg, mapSize)
+		i, s := line, seg
+
+		for len(rows) < br.dispRows && i <= mapSize {
+			if i == 0 || i == mapSize {
+				// SOF and EOF markers
+				if i == mapSize {
+					eofRow = len(rows)
+					rows = append(rows, eof)
+				} else {
+					rows = append(rows, sof)
+				}
+				i, s = i+1, 0
+				continue
+			}
+
+			texts := br.wrapRows(i)
+			for ; s < len(texts) && len(rows) < br.dispRows; s++ {
+				rows = append(rows, texts[s])
+			}
+
+			if s < len(texts) {
+				// partial line at the bottom
+				break
+			}
+
+			i, s = i+1, 0
+		}
+
+		br.firstRow, br.firstSeg = line, seg
+		br.lastRow, br.lastSeg = i, s
+	} else {
+		line = adjustLineNumber(line, br.dispRows, mapSize)
+		// +1 for EOF
+		end := min(line+br.dispRows, mapSize+1)
+
+		for i := line; i < end; i++ {
+			switch {
+
+			case i == 0:
+				rows = append(rows, sof)
+
+			case i == mapSize:
+				eofRow = len(rows)
+				rows = append(rows, eof)
+
+			case i == 1 && br.tabular() && br.table.headerLine():
+				// the header is frozen above the page
+				end = min(end+1, mapSize+1)
+
+			default:
+				rows = append(rows, br.lineText(i))
+			}
+		}
+
+		br.firstRow, br.lastRow = line, end
+	}
+
+	br.setEOFState(eofRow >= 0, eofRow >= 0)
+	br.paintedSize = mapSize
+
+	// frozen rows are painted with the page
+	top := br.paneTop + 2
+	if br.tabular() {
+		rows = append([]string{br.tableHeader()}, rows...)
+		top--
+		if eofRow >= 0 {
+			eofRow++
+		}
+	}
+
+	var sb strings.Builder
+
+	for r := range br.dispRows + br.frozenRows() {
+		row := top + r
+
+		if br.split != nil && br.split.vertical && br.paneLeft > 0 {
+			// the divider goes with the second pane
+			sb.WriteString(fmt.Sprintf(CURPOS, row, br.paneLeft))
+			sb.WriteString(ENTERGRAPHICS + VERTLINE + EXITGRAPHICS)
+		} else {
+			sb.WriteString(fmt.Sprintf(CURPOS, row, br.paneLeft+1))
+		}
+
+		text, cols := "", 0
+		if r < len(rows) {
+			text, cols = clipColumns(rows[r], br.dispWidth)
+		}
+
+		sb.WriteString(text)
+		sb.WriteString(VIDOFF)
+		sb.WriteString(strings.Repeat(" ", br.dispWidth-cols))
+	}
+
+	// reset
+	sb.WriteString(SGR0)
+
+	if eofRow >= 0 && br.hasFocus() {
+		// save for modeScroll
+		sb.WriteString(fmt.Sprintf(CURPOS, top+eofRow, br.paneLeft+1))
+		sb.WriteString(CURSAVEHERE)
+	}
+
+	os.Stdout.WriteString(sb.String())
+
+	if s := br.split; s != nil {
+		s.shown[s.index(br)] = br.paneState()
+	}
+
+	br.shownMsg = false
+	if br.hasFocus() {
+		moveCursor(2, 1, false)
+	}
+}
+
+// paneScroll moves the pane by count rows, up when count is negative, and
+// repaints it if anything changed. It returns true if it painted.
+func (br *browseObj) paneScroll(count int) bool {
+	mapSize := br.currentMapSize()
+	line, seg := br.firstRow, br.firstSeg
+
+	if br.wrapping() {
+		if count > 0 {
+			line, seg = br.wrapForward(line, seg, count, mapSize)
+		} else {
+			line, seg = br.wrapBack(line, seg, -count, mapSize)
+		}
+		line, seg = br.wrapClampTop(line, seg, mapSize)
+	} else {
+		line = adjustLineNumber(line+count, br.dispRows, mapSize)
+	}
+
+	// lines read in below a visible EOF
+	grown := mapSize != br.paintedSize && br.lastRow > br.paintedSize
+
+	if line == br.firstRow && seg == br.firstSeg && !grown && !br.shownMsg {
+		return false
+	}
+
+	br.paintPane(line, seg)
+	if br.hasFocus() {
+		br.restoreCursor()
+	}
+
+	return true
+}
+
+// syncSplit brings the other pane up to date before the next key. It
+// follows this pane when the scroll is locked, is repainted after a key
+// that changed what it shows or ran a prompt across it, and otherwise goes
+// on in its own scroll mode.
+func (br *browseObj) syncSplit(pressed bool) {
+	s := br.split
+	if s == nil {
+		return
+	}
+
+	peer := s.panes[1-s.index(br)]
+	painted := false
+
+	if s.locked && br.firstRow != s.lockRow {
+		painted = peer.paneScroll(br.firstRow - s.lockRow)
+	}
+	s.lockRow = br.firstRow
+
+	if pressed {
+		if !painted && (promptShown || peer.paneState() != s.shown[1-s.index(br)]) {
+			peer.paintPane(peer.firstRow, peer.firstSeg)
+			painted = true
+		}
+		promptShown = false
+	} else {
+		switch peer.modeScroll {
+
+		case MODE_SCROLL_UP:
+			if peer.firstRow > 0 || peer.firstSeg > 0 {
+				painted = peer.paneScroll(-SCROLL_CONT) || painted
+			} else {
+				peer.modeScroll = MODE_SCROLL_NONE
+			}
+
+		case MODE_SCROLL_DN, MODE_SCROLL_FOLLOW:
+			painted = peer.paneScroll(SCROLL_CONT) || painted
+
+		case MODE_SCROLL_TAIL:
+			painted = peer.paneScroll(SCROLL_TAIL) || painted
+		}
+	}
+
+	if painted {
+		br.restoreCursor()
+	}
+}
+
+// vim: set ts=4 sw=4 noet:
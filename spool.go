@@ -0,0 +1,334 @@
+// spool.go
+// copy streamed input into temporary files for browsing
+//
+// Copyright (c) 2024-2026 jjb
+// All rights reserved.
+//
+// This source code is licensed under the MIT license found
+// in the root directory of this source tree.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"sync"
+	"sync/atomic"
+
+	"golang.org/x/sys/unix"
+	"golang.org/x/term"
+)
+
+// SPOOL_STREAM_MAX caps the bytes copied from a pipe or device, so one
+// such as /dev/zero cannot fill the temporary directory.
+const SPOOL_STREAM_MAX = 1 << 30
+
+// spoolObj is a temporary file filled from a stream by a background reader.
+type spoolObj struct {
+	name    string
+	source  string
+	fp      *os.File
+	eof     atomic.Bool
+	stop    chan struct{}
+	once    sync.Once
+	listing *dirListing
+
+	// the stream, closed once by whichever of the reader and close is
+	// first, so a read blocked on a pipe returns
+	src     io.Closer
+	srcOnce sync.Once
+
+	// why the stream is copied raw or only in part
+	warning *atomic.Pointer[string]
+}
+
+// rawWarning returns why the spool holds the raw or a partial stream, or
+// "".
+func (sp *spoolObj) rawWarning() string {
+	if sp.warning == nil {
+		return ""
+	}
+
+	if msg := sp.warning.Load(); msg != nil {
+		return *msg
+	}
+
+	return ""
+}
+
+// decompressWarning records why a stream is copied raw.
+func decompressWarning(warning *atomic.Pointer[string], err error) {
+	msg := fmt.Sprintf("Cannot decompress: %v", err)
+	warning.Store(&msg)
+}
+
+// spoolFiles maps temporary file names to their spools.
+var spoolFiles sync.Map
+
+// newSpool creates a temporary file and starts copying src into it. The
+// first prime bytes are copied before returning so callers can inspect
+// the head of the stream. The spool owns src and closes it when done.
+func newSpool(src io.ReadCloser, source string, prime int) (*spoolObj, error) {
+	fout, err := os.CreateTemp("", "browse")
+	if err != nil {
+		src.Close()
+		return nil, fmt.Errorf("error creating temporary file: %v", err)
+	}
+
+	fp, err := os.Open(fout.Name())
+	if err != nil {
+		fout.Close()
+		os.Remove(fout.Name())
+		src.Close()
+		return nil, fmt.Errorf("cannot open temporary browse file: %v", err)
+	}
+
+	sp := &spoolObj{
+		name:   fout.Name(),
+		source: source,
+		fp:     fp,
+		stop:   make(chan struct{}),
+		src:    src,
+	}
+	spoolFiles.Store(sp.name, sp)
+
+	done := false
+	if prime > 0 {
+		n, err := io.CopyN(fout, src, int64(prime))
+		done = err != nil || n < int64(prime)
+	}
+
+	// Goroutine owns fout and src and closes them when the stream ends
+	go func() {
+		if !done {
+			sp.copy(fout, src)
+		}
+		sp.closeSource()
+		// set before the close so its inotify event finds the spool complete
+		sp.eof.Store(true)
+		fout.Close()
+	}()
+
+	return sp, nil
+}
+
+// copy moves data from src to fout until EOF, error, or close.
+func (sp *spoolObj) copy(fout io.Writer, src io.Reader) {
+	const copyBufSize = 64 * 1024
+
+	buf := make([]byte, copyBufSize)
+
+	for {
+		select {
+		case <-sp.stop:
+			return
+		default:
+		}
+
+		n, err := src.Read(buf)
+		if n > 0 {
+			if _, werr := fout.Write(buf[:n]); werr != nil {
+				return
+			}
+		}
+
+		if err != nil {
+			return
+		}
+	}
+}
+
+// closeSource closes the stream being copied.
+func (sp *spoolObj) closeSource() {
+	sp.srcOnce.Do(func() {
+		sp.src.Close()
+	})
+}
+
+// close stops the background reader and removes the temporary file.
+// Closing the stream ends a read that is waiting on it.
+func (sp *spoolObj) close() {
+	sp.once.Do(func() {
+		close(sp.stop)
+		sp.closeSource()
+		spoolFiles.Delete(sp.name)
+		sp.fp.Close()
+		os.Remove(sp.name)
+	})
+}
+
+// spoolLookup returns the spool backing a temporary file name.
+func spoolLookup(name string) (*spoolObj, bool) {
+	v, ok := spoolFiles.Load(name)
+	if !ok {
+		return nil, false
+	}
+
+	return v.(*spoolObj), true
+}
+
+// isSpoolFile reports whether a path is the temporary file of a spool,
+// which is removed when the spool closes.
+func isSpoolFile(path string) bool {
+	_, ok := spoolLookup(path)
+	return ok
+}
+
+// spoolComplete reports whether a spool has copied its whole stream.
+func spoolComplete(name string) bool {
+	sp, ok := spoolLookup(name)
+	return ok && sp.eof.Load()
+}
+
+// sourceSpools maps pipes, devices and archive members to their spools.
+// They live until exit, so the list can return to them without reading
+// the source again.
+var sourceSpools sync.Map
+
+// isStreamMode reports whether a file mode is a FIFO or character device.
+func isStreamMode(mode fs.FileMode) bool {
+	return mode&(fs.ModeNamedPipe|fs.ModeCharDevice) != 0
+}
+
+// isStreamFile reports whether a path names a FIFO or character device.
+func isStreamFile(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && isStreamMode(info.Mode())
+}
+
+// isTerminalFile reports whether a path names a terminal. Keys are read
+// from the terminal, so it is never spooled.
+func isTerminalFile(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil || info.Mode()&fs.ModeCharDevice == 0 {
+		return false
+	}
+
+	fd, err := unix.Open(path, unix.O_RDONLY|unix.O_NONBLOCK|unix.O_NOCTTY, 0)
+	if err != nil {
+		return false
+	}
+	defer unix.Close(fd)
+
+	return term.IsTerminal(fd)
+}
+
+// spoolStream returns the spool for a pipe or device, starting one on
+// first use.
+func spoolStream(path string) (*spoolObj, error) {
+	if sp, ok := sourceSpool(path); ok {
+		return sp, nil
+	}
+
+	// the stream itself is opened later, by the spool
+	if err := unix.Access(path, unix.R_OK); err != nil {
+		return nil, err
+	}
+
+	warning := new(atomic.Pointer[string])
+
+	sp, err := newSpool(&streamReader{path: path, warning: warning}, path, 0)
+	if err != nil {
+		return nil, err
+	}
+	sp.warning = warning
+
+	sourceSpools.Store(path, sp)
+	return sp, nil
+}
+
+// sourceSpool returns the spool already started for a pipe, device or
+// archive member.
+func sourceSpool(path string) (*spoolObj, bool) {
+	v, ok := sourceSpools.Load(path)
+	if !ok {
+		return nil, false
+	}
+
+	return v.(*spoolObj), true
+}
+
+// streamReader opens a pipe or device on first read, so the wait for a
+// FIFO's writer happens in the spool's reader. It ends the stream after
+// SPOOL_STREAM_MAX bytes. Close may run while a read waits, so the
+// opened stream is kept under a mutex.
+type streamReader struct {
+	path    string
+	mutex   sync.Mutex
+	fp      *os.File
+	src     io.ReadCloser
+	closed  bool
+	copied  int64
+	warning *atomic.Pointer[string]
+}
+
+// Read opens the stream if needed and reads from it.
+func (sr *streamReader) Read(p []byte) (int, error) {
+	if sr.src == nil {
+		fp, err := os.Open(sr.path)
+		if err != nil {
+			return 0, err
+		}
+
+		sr.mutex.Lock()
+		closed := sr.closed
+		if !closed {
+			sr.fp = fp
+		}
+		sr.mutex.Unlock()
+
+		if closed {
+			fp.Close()
+			return 0, os.ErrClosed
+		}
+
+		// raw input is copied if it cannot be decompressed
+		src, err := openDecompressed(fp)
+		if err != nil {
+			decompressWarning(sr.warning, err)
+		}
+
+		sr.mutex.Lock()
+		sr.src = src
+		sr.mutex.Unlock()
+	}
+
+	if sr.copied >= SPOOL_STREAM_MAX {
+		msg := fmt.Sprintf("Stopped copying at %d GB", SPOOL_STREAM_MAX>>30)
+		sr.warning.Store(&msg)
+		return 0, io.EOF
+	}
+
+	n, err := sr.src.Read(p[:min(int64(len(p)), SPOOL_STREAM_MAX-sr.copied)])
+	sr.copied += int64(n)
+	return n, err
+}
+
+// Close closes the stream if it was opened, ending a read that waits.
+func (sr *streamReader) Close() error {
+	sr.mutex.Lock()
+	defer sr.mutex.Unlock()
+
+	sr.closed = true
+	if sr.src != nil {
+		sr.src.Close()
+	}
+
+	if sr.fp == nil {
+		return nil
+	}
+
+	return sr.fp.Close()
+}
+
+// removeSpools removes every spool's temporary file at exit.
+func removeSpools() {
+	spoolFiles.Range(func(_, v any) bool {
+		v.(*spoolObj).close()
+		return true
+	})
+}
+
+// vim: set ts=4 sw=4 noet:
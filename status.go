@@ -0,0 +1,248 @@
+// status.go
+// optional status line at the bottom of the screen
+//
+// Copyright (c) 2024-2026 jjb
+// All rights reserved.
+//
+// This source code is licensed under the MIT license found
+// in the root directory of this source tree.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mattn/go-runewidth"
+)
+
+// Status line fields.
+const (
+	STATUS_NAME = iota
+	STATUS_LINES
+	STATUS_PERCENT
+	STATUS_MODE
+	STATUS_LIST
+	STATUS_DEPTH
+	STATUS_PATTERN
+	STATUS_LOADING
+)
+
+// statusFieldNames name the fields for --status, in display order.
+var statusFieldNames = []string{
+	STATUS_NAME:    "name",
+	STATUS_LINES:   "lines",
+	STATUS_PERCENT: "percent",
+	STATUS_MODE:    "mode",
+	STATUS_LIST:    "list",
+	STATUS_DEPTH:   "depth",
+	STATUS_PATTERN: "pattern",
+	STATUS_LOADING: "loading",
+}
+
+// scrollModeNames describe the scroll modes on the status line.
+var scrollModeNames = []string{
+	MODE_SCROLL_NONE:   "",
+	MODE_SCROLL_UP:     "scroll up",
+	MODE_SCROLL_DN:     "scroll down",
+	MODE_SCROLL_TAIL:   "tail",
+	MODE_SCROLL_FOLLOW: "follow",
+}
+
+// parseStatusFields parses a comma separated list of field names, or
+// "all".
+func parseStatusFields(list string) ([]int, error) {
+	if list == "all" {
+		list = strings.Join(statusFieldNames, ",")
+	}
+
+	var fields []int
+	for _, name := range strings.Split(list, ",") {
+		field := -1
+		for i, n := range statusFieldNames {
+			if n == strings.TrimSpace(name) {
+				field = i
+			}
+		}
+
+		if field < 0 {
+			return nil, fmt.Errorf("unknown status field: %s", name)
+		}
+
+		fields = append(fields, field)
+	}
+
+	return fields, nil
+}
+
+// setScreenSize records the terminal size. The status line, when shown,
+// takes the bottom row, and the rest of browse sees a screen one row
+// shorter. A split screen divides what is left between its panes.
+func (br *browseObj) setScreenSize(width, height int) {
+	rows := height
+	if br.modeStatus {
+		rows--
+	}
+
+	if s := br.split; s != nil {
+		for _, pane := range s.panes {
+			pane.termWidth, pane.termHeight = width, height
+			pane.modeStatus = br.modeStatus
+		}
+
+		s.layout(width, rows)
+		return
+	}
+
+	br.termWidth, br.termHeight = width, height
+	br.setPane(0, 0, width, rows)
+
+	originRow, originCol, originWidth = br.paneTop, 0, 0
+}
+
+// toggleStatus shows or hides the status line.
+func (br *browseObj) toggleStatus() {
+	br.modeStatus = !br.modeStatus
+	br.setScreenSize(br.termWidth, br.termHeight)
+
+	br.pageHeader()
+	br.pageCurrent()
+
+	if br.modeStatus {
+		br.printMessage("Status line on", MSG_GREEN)
+	} else {
+		br.printMessage("Status line off", MSG_GREEN)
+	}
+}
+
+// drawStatus draws the status line when its text has changed, and puts
+// the cursor back where the page left it.
+func (br *browseObj) drawStatus() {
+	if !br.modeStatus {
+		return
+	}
+
+	text := br.statusText()
+	if text == br.statusShown {
+		return
+	}
+	br.statusShown = text
+
+	// the status line is below any split
+	fmt.Printf(CURPOS, br.termHeight, 1)
+	os.Stdout.WriteString(VIDSTATUS + text + VIDOFF + CLEARLINE)
+
+	if br.inFollow() {
+		fmt.Print(CURRESTORE)
+	} else {
+		moveCursor(2, 1, false)
+	}
+}
+
+// statusText returns the status line, fitted to the screen width.
+func (br *browseObj) statusText() string {
+	fields := br.statusFields
+	if fields == nil {
+		fields, _ = parseStatusFields("all")
+	}
+
+	var parts []string
+	for _, field := range fields {
+		if part := br.statusField(field); part != "" {
+			parts = append(parts, part)
+		}
+	}
+
+	// the last column is left clear, as for page lines
+	width := max(br.termWidth-1, 0)
+	text := runewidth.Truncate(" "+strings.Join(parts, "  "), width, "...")
+	return runewidth.FillRight(text, width)
+}
+
+// statusField returns the text of one status field, or "" if it has
+// nothing to show.
+func (br *browseObj) statusField(field int) string {
+	br.mutex.Lock()
+	mapSize := br.mapSiz
+	filtered := br.filter != nil
+	scanLines := br.scanLines
+	loading := br.fromStdin && !br.stdinComplete()
+	br.mutex.Unlock()
+
+	// line 0 is the header
+	lineCount := max(mapSize-1, 0)
+	top := min(max(br.firstRow, 1), lineCount)
+	bottom := min(max(br.lastRow-1, top), lineCount)
+
+	// a filtered view counts the lines of the file, as the line numbers do
+	if filtered {
+		lineCount = scanLines
+		top, bottom = br.origLine(top), br.origLine(bottom)
+	}
+
+	switch field {
+
+	case STATUS_NAME:
+		if br.fromStdin {
+			return "stdin"
+		}
+		return filepath.Base(br.sourceName())
+
+	case STATUS_LINES:
+		return fmt.Sprintf("lines %d-%d/%d", top, bottom, lineCount)
+
+	case STATUS_PERCENT:
+		if lineCount == 0 {
+			return "0%"
+		}
+		return fmt.Sprintf("%d%%", bottom*100/lineCount)
+
+	case STATUS_MODE:
+		return scrollModeNames[br.modeScroll]
+
+	case STATUS_LIST:
+		if br.listLen > 1 {
+			return fmt.Sprintf("file %d of %d", br.listLen-len(br.currentList)+1, br.listLen)
+		}
+
+	case STATUS_DEPTH:
+		if br.listDepth > 1 {
+			return fmt.Sprintf("depth %d", br.listDepth)
+		}
+
+	case STATUS_PATTERN:
+		if br.pattern != "" {
+			return "/" + br.pattern + "/" + br.patternFlags()
+		}
+
+	case STATUS_LOADING:
+		if loading {
+			return "loading"
+		}
+	}
+
+	return ""
+}
+
+// patternFlags returns the search mode keys in effect: i when case is
+// ignored, by i or by smart case, I for fixed strings and W for whole
+// words.
+func (br *browseObj) patternFlags() string {
+	flags := ""
+
+	if br.caseless(br.pattern) {
+		flags += "i"
+	}
+	if br.searchFixed {
+		flags += "I"
+	}
+	if br.wholeWord {
+		flags += "W"
+	}
+
+	return flags
+}
+
+// vim: set ts=4 sw=4 noet:
@@ -0,0 +1,352 @@
+// syntax.go
+// color source and config files by language
+//
+// Copyright (c) 2024-2026 jjb
+// All rights reserved.
+//
+// This source code is licensed under the MIT license found
+// in the root directory of this source tree.
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Syntax token classes.
+const (
+	TOKEN_KEYWORD = iota
+	TOKEN_CONSTANT
+	TOKEN_STRING
+	TOKEN_NUMBER
+	TOKEN_COMMENT
+	TOKEN_KEY
+)
+
+// tokenColors are the colors of the token classes. Search highlights are
+// backgrounds, so they stay visible over these.
+var tokenColors = []string{
+	TOKEN_KEYWORD:  _VID_BLUE_FG,
+	TOKEN_CONSTANT: _VID_MAGENTA_FG,
+	TOKEN_STRING:   _VID_YELLOW_FG,
+	TOKEN_NUMBER:   _VID_MAGENTA_FG,
+	TOKEN_COMMENT:  _VID_GREY_FG,
+	TOKEN_KEY:      _VID_CYAN_FG,
+}
+
+// syntaxLang describes how to color a language. Lines are colored one at
+// a time, so comments and strings that span lines are not followed.
+type syntaxLang struct {
+	name      string
+	comment   string
+	quotes    string
+	block     bool
+	yamlKeys  bool
+	jsonKeys  bool
+	variables bool
+	keywords  map[string]bool
+	constants map[string]bool
+}
+
+// syntaxLangs are the languages that can be colored.
+var syntaxLangs = map[string]*syntaxLang{
+	"go": {
+		name:    "Go",
+		comment: "//",
+		quotes:  "\"'`",
+		block:   true,
+		keywords: wordSet("break case chan const continue default defer else " +
+			"fallthrough for func go goto if import interface map package " +
+			"range return select struct switch type var"),
+		constants: wordSet("true false nil iota any bool byte comparable " +
+			"complex64 complex128 error float32 float64 int int8 int16 int32 " +
+			"int64 rune string uint uint8 uint16 uint32 uint64 uintptr"),
+	},
+
+	"yaml": {
+		name:      "YAML",
+		comment:   "#",
+		quotes:    "\"'",
+		yamlKeys:  true,
+		constants: wordSet("true false null yes no on off True False Null"),
+	},
+
+	"json": {
+		name:      "JSON",
+		quotes:    "\"",
+		jsonKeys:  true,
+		constants: wordSet("true false null"),
+	},
+
+	"shell": {
+		name:      "shell",
+		comment:   "#",
+		quotes:    "\"'",
+		variables: true,
+		keywords: wordSet("if then else elif fi case esac for select while " +
+			"until do done in function time return local export readonly " +
+			"declare unset shift exit break continue source eval exec trap"),
+		constants: wordSet("true false"),
+	},
+}
+
+// syntaxNames map file extensions, file names and interpreters to
+// languages.
+var syntaxNames = map[string]string{
+	".go":           "go",
+	".yaml":         "yaml",
+	".yml":          "yaml",
+	".json":         "json",
+	".jsonl":        "json",
+	".sh":           "shell",
+	".bash":         "shell",
+	".zsh":          "shell",
+	".ksh":          "shell",
+	".bashrc":       "shell",
+	".bash_profile": "shell",
+	".profile":      "shell",
+	".zshrc":        "shell",
+	"sh":            "shell",
+	"bash":          "shell",
+	"zsh":           "shell",
+	"ksh":           "shell",
+	"dash":          "shell",
+}
+
+// compressedExts are stripped before a file extension is looked up.
+var compressedExts = []string{".gz", ".bz2", ".xz", ".zst"}
+
+// wordSet makes a set of space separated words.
+func wordSet(words string) map[string]bool {
+	set := make(map[string]bool)
+	for _, w := range strings.Fields(words) {
+		set[w] = true
+	}
+
+	return set
+}
+
+// detectSyntax chooses a language by file extension, or by the
+// interpreter on a #! line. It returns nil for other files.
+func detectSyntax(fp *os.File, fileName string) *syntaxLang {
+	base := filepath.Base(fileName)
+	for _, ext := range compressedExts {
+		base = strings.TrimSuffix(base, ext)
+	}
+
+	// dot files such as .bashrc are all extension
+	if lang, ok := syntaxNames[filepath.Ext(base)]; ok {
+		return syntaxLangs[lang]
+	}
+
+	head := make([]byte, 128)
+	n, _ := fp.ReadAt(head, 0)
+	return syntaxLangs[syntaxNames[interpreter(head[:n])]]
+}
+
+// interpreter returns the program named by a #! line, looking past env.
+func interpreter(head []byte) string {
+	if !bytes.HasPrefix(head, []byte("#!")) {
+		return ""
+	}
+
+	line, _, _ := bytes.Cut(head[2:], []byte{'\n'})
+	fields := strings.Fields(string(line))
+	if len(fields) == 0 {
+		return ""
+	}
+
+	prog := filepath.Base(fields[0])
+	if prog == "env" && len(fields) > 1 {
+		prog = fields[1]
+	}
+
+	return prog
+}
+
+// syntaxSpans colors a line for its language, unless syntax coloring is
+// off or the line has colors of its own.
+func (br *browseObj) syntaxSpans(line []byte, spans []sgrSpan) []sgrSpan {
+	if !br.modeSyntax || br.syntax == nil || spans != nil {
+		return spans
+	}
+
+	return br.syntax.colorLine(line)
+}
+
+// colorLine returns the color changes for the tokens of a line.
+func (lang *syntaxLang) colorLine(line []byte) []sgrSpan {
+	var spans []sgrSpan
+	color := func(start, end, token int) {
+		spans = append(spans,
+			sgrSpan{pos: start, sgr: tokenColors[token]},
+			sgrSpan{pos: end, sgr: _VID_OFF})
+	}
+
+	i := 0
+	if lang.yamlKeys {
+		if end := yamlKey(line); end > 0 {
+			start := len(line) - len(bytes.TrimLeft(line, " -"))
+			color(start, end, TOKEN_KEY)
+			i = end
+		}
+	}
+
+	for i < len(line) {
+		c := line[i]
+
+		switch {
+
+		case lang.comment != "" && hasPrefix(line[i:], lang.comment) &&
+			(lang.comment != "#" || i == 0 || line[i-1] == ' '):
+			color(i, len(line), TOKEN_COMMENT)
+			return spans
+
+		case lang.block && hasPrefix(line[i:], "/*"):
+			end := len(line)
+			if j := bytes.Index(line[i+2:], []byte("*/")); j >= 0 {
+				end = i + 2 + j + 2
+			}
+			color(i, end, TOKEN_COMMENT)
+			i = end
+
+		case strings.IndexByte(lang.quotes, c) >= 0 && (i == 0 || !isWordByte(line[i-1])):
+			// a quote inside a word is an apostrophe
+			end := quotedEnd(line, i, c != '`' && !(lang.variables && c == '\''))
+			token := TOKEN_STRING
+			if lang.jsonKeys && bytes.HasPrefix(bytes.TrimLeft(line[end:], " "), []byte{':'}) {
+				token = TOKEN_KEY
+			}
+			color(i, end, token)
+			i = end
+
+		case lang.variables && c == '$' && i+1 < len(line) &&
+			(isWordByte(line[i+1]) || strings.IndexByte("{?#@*!$-", line[i+1]) >= 0):
+			end := variableEnd(line, i)
+			color(i, end, TOKEN_KEY)
+			i = end
+
+		case isWordByte(c):
+			end := i + 1
+			for end < len(line) && (isWordByte(line[end]) || (c >= '0' && c <= '9' && line[end] == '.')) {
+				end++
+			}
+
+			switch {
+			case c >= '0' && c <= '9':
+				color(i, end, TOKEN_NUMBER)
+			case lang.keywords[string(line[i:end])]:
+				color(i, end, TOKEN_KEYWORD)
+			case lang.constants[string(line[i:end])]:
+				color(i, end, TOKEN_CONSTANT)
+			}
+			i = end
+
+		default:
+			i++
+		}
+	}
+
+	return spans
+}
+
+// yamlKey returns the end of the key on a YAML mapping line, or 0.
+func yamlKey(line []byte) int {
+	start := len(line) - len(bytes.TrimLeft(line, " -"))
+	if start == len(line) || line[start] == '#' {
+		return 0
+	}
+
+	for i := start; i < len(line); i++ {
+		switch line[i] {
+
+		case ':':
+			if i+1 == len(line) || line[i+1] == ' ' {
+				return i
+			}
+
+		case '"', '\'', '#', '{', '[':
+			return 0
+		}
+	}
+
+	return 0
+}
+
+// quotedEnd returns the end of the string starting at start, or the end
+// of the line for strings that continue.
+func quotedEnd(line []byte, start int, escapes bool) int {
+	quote := line[start]
+	for i := start + 1; i < len(line); i++ {
+		if escapes && line[i] == '\\' {
+			i++
+			continue
+		}
+
+		if line[i] == quote {
+			return i + 1
+		}
+	}
+
+	return len(line)
+}
+
+// variableEnd returns the end of a shell variable reference.
+func variableEnd(line []byte, start int) int {
+	i := start + 1
+	if line[i] == '{' {
+		if j := bytes.IndexByte(line[i:], '}'); j >= 0 {
+			return i + j + 1
+		}
+		return len(line)
+	}
+
+	if !isWordByte(line[i]) {
+		// special parameters such as $? and $#
+		return i + 1
+	}
+
+	if line[i] >= '0' && line[i] <= '9' {
+		// positional parameters take one digit
+		return i + 1
+	}
+
+	for i < len(line) && isWordByte(line[i]) {
+		i++
+	}
+
+	return i
+}
+
+// hasPrefix reports whether line begins with prefix.
+func hasPrefix(line []byte, prefix string) bool {
+	return len(line) >= len(prefix) && string(line[:len(prefix)]) == prefix
+}
+
+// isWordByte reports whether a byte can be part of an identifier.
+func isWordByte(b byte) bool {
+	return b == '_' || (b >= '0' && b <= '9') || (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z')
+}
+
+// toggleSyntax turns syntax coloring on or off.
+func (br *browseObj) toggleSyntax() {
+	br.modeSyntax = !br.modeSyntax
+	br.pageCurrent()
+
+	switch {
+
+	case !br.modeSyntax:
+		br.printMessage("Syntax coloring off", MSG_GREEN)
+
+	case br.syntax == nil:
+		br.printMessage("Syntax coloring on, none for this file", MSG_ORANGE)
+
+	default:
+		br.printMessage("Syntax coloring for "+br.syntax.name, MSG_GREEN)
+	}
+}
+
+// vim: set ts=4 sw=4 noet:
@@ -0,0 +1,576 @@
+// table.go
+// column view of CSV and TSV files
+//
+// Copyright (c) 2024-2026 jjb
+// All rights reserved.
+//
+// This source code is licensed under the MIT license found
+// in the root directory of this source tree.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/mattn/go-runewidth"
+)
+
+// Column view limits.
+const (
+	// bytes read from the head of the file to find the delimiter and the
+	// column widths
+	TABLE_SAMPLE_SIZE = 64 << 10
+
+	// the widest column; longer fields are cut
+	TABLE_MAX_WIDTH = 40
+
+	// lines of the sample that must have the header's field count
+	TABLE_MATCH_PERCENT = 90
+)
+
+// TABLE_SEP goes between columns.
+const TABLE_SEP = " │ "
+
+// tableDelims are the delimiters tried, in order of preference.
+var tableDelims = []byte{',', '\t', ';', '|'}
+
+// tableNames map file extensions to their delimiters.
+var tableNames = map[string]byte{
+	".csv": ',',
+	".tsv": '\t',
+	".tab": '\t',
+}
+
+// tableView lays out delimited lines, or the fields of records, as aligned
+// columns. The first line of a delimited file names its columns; records
+// are headed by their field names. The header stays at the top of the
+// page.
+type tableView struct {
+	delim  byte
+	names  []string
+	widths []int
+
+	// the columns shown, in order, and the first of them on the left
+	order []int
+	first int
+}
+
+// tableName returns the delimiter a file name implies, or 0.
+func tableName(fileName string) byte {
+	base := filepath.Base(fileName)
+	for _, ext := range compressedExts {
+		base = strings.TrimSuffix(base, ext)
+	}
+
+	return tableNames[strings.ToLower(filepath.Ext(base))]
+}
+
+// sampleLines returns the lines at the head of a file that are not blank.
+func sampleLines(fp *os.File) [][]byte {
+	head := make([]byte, TABLE_SAMPLE_SIZE)
+	n, _ := fp.ReadAt(head, 0)
+	head = head[:n]
+
+	// a line cut by the end of the sample is left out
+	lines := bytes.Split(head, []byte{'\n'})
+	if n == TABLE_SAMPLE_SIZE && len(lines) > 1 {
+		lines = lines[:len(lines)-1]
+	}
+
+	return slices.DeleteFunc(lines, func(line []byte) bool {
+		return len(bytes.TrimSpace(line)) == 0
+	})
+}
+
+// detectTable looks for a delimiter that splits the head of a file into
+// the same number of fields on nearly every line. It returns nil for
+// files that are not tables.
+func detectTable(fp *os.File, fileName string) *tableView {
+	lines := sampleLines(fp)
+	if len(lines) == 0 {
+		return nil
+	}
+
+	delims := tableDelims
+	if d := tableName(fileName); d != 0 {
+		delims = append([]byte{d}, tableDelims...)
+	}
+
+	for _, delim := range delims {
+		names := splitFields(lines[0], delim)
+		if len(names) < 2 {
+			continue
+		}
+
+		rows := make([][]string, 0, len(lines))
+		for _, line := range lines {
+			if fields := splitFields(line, delim); len(fields) == len(names) {
+				rows = append(rows, fields)
+			}
+		}
+
+		if len(rows)*100 < len(lines)*TABLE_MATCH_PERCENT {
+			continue
+		}
+
+		t := &tableView{delim: delim}
+		for i, name := range names {
+			t.names = append(t.names, fieldText(name))
+			t.order = append(t.order, i)
+		}
+
+		t.widths = make([]int, len(names))
+		for _, row := range rows {
+			for i, field := range row {
+				w := runewidth.StringWidth(fieldText(field))
+				t.widths[i] = min(max(t.widths[i], w), TABLE_MAX_WIDTH)
+			}
+		}
+
+		return t
+	}
+
+	return nil
+}
+
+// splitFields splits a line at a delimiter. Outside of tabs, fields may be
+// quoted, with "" for a quote inside them.
+func splitFields(line []byte, delim byte) []string {
+	line = bytes.TrimRight(line, "\r\n")
+
+	var fields []string
+	var field strings.Builder
+	quoted := false
+
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+
+		switch {
+
+		case quoted && c == '"':
+			if i+1 < len(line) && line[i+1] == '"' {
+				field.WriteByte('"')
+				i++
+			} else {
+				quoted = false
+			}
+
+		case quoted:
+			field.WriteByte(c)
+
+		case c == '"' && delim != '\t' && field.Len() == 0:
+			quoted = true
+
+		case c == delim:
+			fields = append(fields, field.String())
+			field.Reset()
+
+		default:
+			field.WriteByte(c)
+		}
+	}
+
+	return append(fields, field.String())
+}
+
+// fieldText returns a field as it is shown, with control characters in
+// caret notation so they cannot upset the columns.
+func fieldText(field string) string {
+	text, _ := visibleText([]byte(field), CTRL_CARET)
+	return string(text)
+}
+
+// layout returns the columns shown of a line, cut and padded to their
+// widths.
+func (t *tableView) layout(fields []string) string {
+	var sb strings.Builder
+
+	for k, col := range t.order[t.first:] {
+		if k > 0 {
+			sb.WriteString(TABLE_SEP)
+		}
+
+		text := ""
+		if col < len(fields) {
+			text = fieldText(fields[col])
+		}
+
+		text = runewidth.Truncate(text, t.widths[col], "…")
+		sb.WriteString(runewidth.FillRight(text, t.widths[col]))
+	}
+
+	return sb.String()
+}
+
+// fieldTextMap returns a field as fieldText shows it, and where each byte
+// of the field, and its end, lands in the text.
+func fieldTextMap(field string) (string, []int) {
+	line := []byte(field)
+	at := make([]int, len(line)+1)
+
+	if hasControls(line) {
+		// as in visibleText, a CRLF ending shows no ^M
+		line = bytes.TrimSuffix(line, []byte{'\r'})
+	}
+
+	var buf bytes.Buffer
+	cols := 0
+
+	for i := 0; i < len(line); {
+		at[i] = buf.Len()
+
+		switch b := line[i]; {
+
+		case b == '\t':
+			spaces := TABWIDTH - cols%TABWIDTH
+			buf.Write(tabSpaces[:spaces])
+			cols += spaces
+			i++
+
+		case isControl(b):
+			buf.WriteByte('^')
+			buf.WriteByte(b ^ 0x40)
+			cols += 2
+			i++
+
+		default:
+			size, w := nextRune(line[i:])
+			for j := i + 1; j < i+size; j++ {
+				at[j] = at[i]
+			}
+
+			buf.Write(line[i : i+size])
+			cols += w
+			i += size
+		}
+	}
+
+	for i := len(line); i < len(at); i++ {
+		at[i] = buf.Len()
+	}
+
+	return buf.String(), at
+}
+
+// cellMatches returns where the matches of re in the fields of a line are
+// shown in its layout. Each field is matched as it is, before caret
+// notation and cutting, and a match cut off at the column's width marks
+// the "…" that ends it. Only column only is matched unless it is -1.
+func (t *tableView) cellMatches(fields []string, re *regexp.Regexp, only int) [][]int {
+	var matches [][]int
+	pos := 0
+
+	for k, col := range t.order[t.first:] {
+		if k > 0 {
+			pos += len(TABLE_SEP)
+		}
+
+		field := ""
+		if col < len(fields) {
+			field = fields[col]
+		}
+
+		text, at := fieldTextMap(field)
+		cut := runewidth.Truncate(text, t.widths[col], "…")
+
+		kept := len(cut)
+		if cut != text {
+			kept -= len("…")
+		}
+
+		if only < 0 || col == only {
+			for _, m := range re.FindAllStringIndex(field, -1) {
+				start, end := min(at[m[0]], kept), at[m[1]]
+				if end > kept {
+					end = len(cut)
+				}
+
+				if start < end {
+					matches = append(matches, []int{pos + start, pos + end})
+				}
+			}
+		}
+
+		pos += len(runewidth.FillRight(cut, t.widths[col]))
+	}
+
+	return matches
+}
+
+// header returns the column names as they head the page.
+func (t *tableView) header() string {
+	return t.layout(t.names)
+}
+
+// records reports whether the columns are the fields of records rather
+// than delimited.
+func (t *tableView) records() bool {
+	return t.delim == 0
+}
+
+// headerLine reports whether the first line of the file is the header.
+func (t *tableView) headerLine() bool {
+	return !t.records()
+}
+
+// fields returns the fields of a line in column order. Lines that are not
+// records have none.
+func (t *tableView) fields(line []byte) ([]string, bool) {
+	if !t.records() {
+		return splitFields(line, t.delim), true
+	}
+
+	rec, ok := parseRecord(line)
+	if !ok {
+		return nil, false
+	}
+
+	fields := make([]string, len(t.names))
+	for i, name := range t.names {
+		fields[i] = rec.values[name]
+	}
+
+	return fields, true
+}
+
+// tabular reports whether lines are shown as columns. Hex rows are never
+// columns.
+func (br *browseObj) tabular() bool {
+	return br.modeTable && !br.modeHex
+}
+
+// frozenRows returns the rows held at the top of the page.
+func (br *browseObj) frozenRows() int {
+	if br.tabular() {
+		return 1
+	}
+
+	return 0
+}
+
+// tableRow is the line last laid out as columns, kept for its highlights.
+type tableRow struct {
+	lineno int
+	fields []string
+	ok     bool
+}
+
+// tableLine returns a line laid out as columns. A line that is not a
+// record, such as a stack trace in a log, is shown as it is.
+func (br *browseObj) tableLine(lineno int) []byte {
+	line := br.readDecoded(lineno)
+
+	fields, ok := br.table.fields(line)
+	br.tableRow = tableRow{lineno: lineno, fields: fields, ok: ok}
+
+	if !ok {
+		return []byte(fieldText(string(line)))
+	}
+
+	return []byte(br.table.layout(fields))
+}
+
+// tableMatches returns where re matches the fields of a line in its
+// layout; with narrow set, only the field a search is narrowed to. It
+// returns false for a line that is not a record.
+func (br *browseObj) tableMatches(lineno int, re *regexp.Regexp, narrow bool) ([][]int, bool) {
+	row := br.tableRow
+	if row.lineno != lineno {
+		row.fields, row.ok = br.table.fields(br.readDecoded(lineno))
+	}
+
+	if !row.ok {
+		return nil, false
+	}
+
+	only := -1
+	if narrow && br.field != nil {
+		only = br.field.col
+	}
+
+	return br.table.cellMatches(row.fields, re, only), true
+}
+
+// tableHeader returns the header row, lined up with the rows under it.
+func (br *browseObj) tableHeader() string {
+	indent := ""
+	if br.modeNumbers {
+		indent = strings.Repeat(" ", NUMCOLWIDTH)
+	}
+
+	return indent + _VID_BOLD + br.table.header() + VIDOFF
+}
+
+// toggleTable switches between lines and columns. The records or the
+// delimiter are looked for the first time columns are asked for.
+func (br *browseObj) toggleTable() {
+	if br.modeHex || br.dirList != nil {
+		br.printMessage("No columns in this view", MSG_ORANGE)
+		return
+	}
+
+	if br.table == nil {
+		br.mutex.Lock()
+		fp := br.fp
+		br.mutex.Unlock()
+
+		if fp != nil {
+			br.table = detectColumns(fp, br.sourceName())
+		}
+
+		if br.table == nil {
+			br.printMessage("No columns found", MSG_ORANGE)
+			return
+		}
+	}
+
+	br.modeTable = !br.modeTable
+	br.shiftWidth = 0
+
+	// name=pattern narrows a search only in column view
+	br.reCompile(br.pattern)
+
+	br.setScreenSize(br.termWidth, br.termHeight)
+	br.pageHeader()
+	br.pageCurrent()
+
+	if br.modeTable {
+		br.printMessage(fmt.Sprintf("Column view, %d columns", len(br.table.names)), MSG_GREEN)
+	} else {
+		br.printMessage("Line view", MSG_GREEN)
+	}
+}
+
+// shiftColumns moves the view a column at a time for the horizontal
+// scroll keys.
+func (br *browseObj) shiftColumns(key byte) {
+	t := br.table
+	last := len(t.order) - 1
+
+	switch key {
+
+	case CMD_SHIFT_LEFT, CMD_SHIFT_LEFT_1, CMD_SHIFT_LEFT_2:
+		t.first = max(t.first-1, 0)
+
+	case CMD_SHIFT_RIGHT, CMD_SHIFT_RIGHT_1:
+		t.first = min(t.first+1, last)
+
+	case CMD_SHIFT_ZERO:
+		t.first = 0
+
+	case CMD_SHIFT_LONGEST:
+		// as many of the last columns as fit
+		width := br.dispWidth - 1
+		if br.modeNumbers {
+			width -= NUMCOLWIDTH
+		}
+
+		t.first = last
+		width -= t.widths[t.order[last]]
+		for t.first > 0 {
+			width -= t.widths[t.order[t.first-1]] + runewidth.StringWidth(TABLE_SEP)
+			if width < 0 {
+				break
+			}
+			t.first--
+		}
+	}
+
+	br.pageCurrent()
+}
+
+// columnsCommand prompts for the columns to show, by number or name, in
+// the order to show them. Ranges such as 2-5 are allowed, a leading !
+// hides the columns listed instead, and an empty answer shows them all.
+func (br *browseObj) columnsCommand() {
+	if !br.tabular() {
+		br.printMessage("Not in column view", MSG_ORANGE)
+		return
+	}
+
+	lbuf, cancelled := br.userInput("Columns: ")
+	if cancelled {
+		return
+	}
+
+	t := br.table
+	list, hide := strings.CutPrefix(strings.TrimSpace(lbuf), "!")
+
+	cols, bad := t.parseColumns(list)
+	if bad != "" {
+		br.printMessage("No such column: "+bad, MSG_ORANGE)
+		return
+	}
+
+	switch {
+
+	case len(cols) == 0:
+		cols = nil
+		for i := range t.names {
+			cols = append(cols, i)
+		}
+
+	case hide:
+		var shown []int
+		for i := range t.names {
+			if !slices.Contains(cols, i) {
+				shown = append(shown, i)
+			}
+		}
+		cols = shown
+	}
+
+	if len(cols) == 0 {
+		br.printMessage("No columns left to show", MSG_ORANGE)
+		return
+	}
+
+	t.order, t.first = cols, 0
+	br.pageCurrent()
+	br.printMessage(fmt.Sprintf("Showing %d of %d columns", len(cols), len(t.names)), MSG_GREEN)
+}
+
+// parseColumns reads a list of column numbers, ranges and names,
+// separated by commas or spaces. It returns the first item that names no
+// column, if any.
+func (t *tableView) parseColumns(list string) ([]int, string) {
+	var cols []int
+
+	for _, item := range strings.FieldsFunc(list, func(r rune) bool { return r == ',' || r == ' ' }) {
+		if lo, hi, ok := strings.Cut(item, "-"); ok {
+			from, err1 := strconv.Atoi(lo)
+			to, err2 := strconv.Atoi(hi)
+			if err1 == nil && err2 == nil && from >= 1 && to <= len(t.names) && from <= to {
+				for col := from; col <= to; col++ {
+					cols = append(cols, col-1)
+				}
+				continue
+			}
+		}
+
+		if n, err := strconv.Atoi(item); err == nil && n >= 1 && n <= len(t.names) {
+			cols = append(cols, n-1)
+			continue
+		}
+
+		if i := slices.IndexFunc(t.names, func(name string) bool {
+			return strings.EqualFold(strings.TrimSpace(name), item)
+		}); i >= 0 {
+			cols = append(cols, i)
+			continue
+		}
+
+		return nil, item
+	}
+
+	return cols, ""
+}
+
+// vim: set ts=4 sw=4 noet:
@@ -85,6 +85,7 @@ func (br *browseObj) userInput(promptStr string) (string, bool) {
 	}()
 
 	ttyPrompter()
+	promptShown = true
 	fmt.Print(CURSAVE)
 	moveCursor(br.dispHeight, 1, true)
 	fmt.Print(promptStr)
@@ -18,6 +18,8 @@ import (
 	"regexp"
 	"strings"
 	"sync"
+
+	"github.com/mattn/go-runewidth"
 )
 
 var tabSpaces = [TABWIDTH]byte{' ', ' ', ' ', ' '}
@@ -57,19 +59,29 @@ func expandTabs(data []byte) []byte {
 	tabCount := bytes.Count(data, []byte{'\t'})
 	buf.Grow(len(data) + tabCount*(TABWIDTH-1))
 
-	for _, b := range data {
-		switch b {
+	// tab stops are in columns, which wide characters take two of
+	cols := 0
+	for i := 0; i < len(data); {
+		switch b := data[i]; b {
 
 		case '\r', '\f':
 			buf.WriteByte(' ')
+			cols++
 
 		case '\t':
-			spaces := TABWIDTH - (buf.Len() % TABWIDTH)
+			spaces := TABWIDTH - (cols % TABWIDTH)
 			buf.Write(tabSpaces[:spaces])
+			cols += spaces
 
 		default:
-			buf.WriteByte(b)
+			size, w := nextRune(data[i:])
+			buf.Write(data[i : i+size])
+			cols += w
+			i += size
+			continue
 		}
+
+		i++
 	}
 
 	result := make([]byte, buf.Len())
@@ -78,8 +90,18 @@ func expandTabs(data []byte) []byte {
 	return result
 }
 
-// moveCursor positions the cursor and optionally clears the line.
+// moveCursor positions the cursor and optionally clears the line. Rows and
+// columns count from the focused pane of a split screen.
 func moveCursor(row, col int, clrflag bool) {
+	row, col = row+originRow, col+originCol
+
+	if clrflag && originWidth > 0 {
+		// CLEARLINE would clear the pane beside this one too
+		fmt.Printf(CURPOS+"%s"+CURPOS, row, col,
+			strings.Repeat(" ", max(originCol+originWidth-col+1, 0)), row, col)
+		return
+	}
+
 	if clrflag {
 		fmt.Printf(CURPOS+CLEARLINE, row, col)
 		return
@@ -231,11 +253,12 @@ func lastNChars(s string, dispWidth int) string {
 	const padding = 45
 
 	usable := max(maximum(dispWidth-padding, dispWidth>>1), 0)
-	runes := []rune(s)
-	runeLen := len(runes)
-	size := minimum(usable, runeLen)
+	width := runewidth.StringWidth(s)
+	if width <= usable {
+		return s
+	}
 
-	return string(runes[len(runes)-size:])
+	return runewidth.TruncateLeft(s, width-usable, "")
 }
 
 // shellEscapeSingle safely single-quotes a string for the shell.
@@ -269,7 +292,7 @@ func fdLinkPath(fd int) string {
 
 // abbreviateFileName abbreviates the file name if it's too long for display.
 func abbreviateFileName(dispName string, availableWidth int) string {
-	if len(dispName) <= availableWidth {
+	if runewidth.StringWidth(dispName) <= availableWidth {
 		return dispName
 	}
 
@@ -281,7 +304,7 @@ func abbreviateFileName(dispName string, availableWidth int) string {
 	abbrevName := "..." + "/" + filepath.Base(parentDir) + "/" + baseName
 
 	// If still too long, just use parent/base format without ellipsis
-	if len(abbrevName) > availableWidth {
+	if runewidth.StringWidth(abbrevName) > availableWidth {
 		abbrevName = filepath.Base(parentDir) + "/" + baseName
 	}
 
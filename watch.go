@@ -0,0 +1,203 @@
+// watch.go
+// wake the file reader on inotify events, or poll
+//
+// Copyright (c) 2024-2026 jjb
+// All rights reserved.
+//
+// This source code is licensed under the MIT license found
+// in the root directory of this source tree.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// Watcher timing.
+const (
+	// poll interval when inotify is unavailable
+	WATCH_POLL = time.Second
+
+	// backstop for missed events when inotify is active
+	WATCH_BACKSTOP = 10 * time.Second
+)
+
+// inotify event masks for the file and its parent directory.
+const (
+	watchFileMask = unix.IN_MODIFY | unix.IN_ATTRIB | unix.IN_CLOSE_WRITE |
+		unix.IN_MOVE_SELF | unix.IN_DELETE_SELF
+	watchDirMask = unix.IN_CREATE | unix.IN_MOVED_TO | unix.IN_MOVED_FROM |
+		unix.IN_DELETE
+)
+
+// fileWatcher wakes the reader when the watched file changes.
+type fileWatcher struct {
+	file     *os.File
+	fd       int
+	mutex    sync.Mutex
+	fileWd   int
+	dirWd    int
+	baseName string
+	events   chan struct{}
+}
+
+// newFileWatcher watches fileName, falling back to polling when inotify
+// is unavailable or the filesystem does not deliver its events.
+func newFileWatcher(fileName string) *fileWatcher {
+	w := &fileWatcher{
+		fileWd: -1,
+		dirWd:  -1,
+		events: make(chan struct{}, 1),
+	}
+
+	if !inotifySupported(fileName) {
+		return w
+	}
+
+	fd, err := unix.InotifyInit1(unix.IN_NONBLOCK | unix.IN_CLOEXEC)
+	if err != nil {
+		return w
+	}
+
+	// non-blocking, so reads go through the runtime poller and Close
+	// unblocks them; calling Fd() would undo that, so keep our own copy
+	w.fd = fd
+	w.file = os.NewFile(uintptr(fd), "inotify")
+	if !w.watch(fileName) {
+		w.file.Close()
+		w.file = nil
+		return w
+	}
+
+	go w.readEvents()
+	return w
+}
+
+// inotifySupported reports whether local change events reach fileName.
+func inotifySupported(fileName string) bool {
+	var stat unix.Statfs_t
+
+	if err := unix.Statfs(fileName, &stat); err != nil {
+		return false
+	}
+
+	switch uint32(stat.Type) {
+
+	case unix.NFS_SUPER_MAGIC, unix.SMB_SUPER_MAGIC, unix.SMB2_SUPER_MAGIC,
+		unix.CIFS_SUPER_MAGIC, unix.FUSE_SUPER_MAGIC, unix.V9FS_MAGIC,
+		unix.CEPH_SUPER_MAGIC:
+		return false
+	}
+
+	return true
+}
+
+// watch replaces the current watches with watches on fileName and its
+// parent directory. The directory watch catches files that reappear.
+func (w *fileWatcher) watch(fileName string) bool {
+	if w.file == nil {
+		return false
+	}
+
+	fd := w.fd
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.fileWd >= 0 {
+		unix.InotifyRmWatch(fd, uint32(w.fileWd))
+		w.fileWd = -1
+	}
+
+	if w.dirWd >= 0 {
+		unix.InotifyRmWatch(fd, uint32(w.dirWd))
+		w.dirWd = -1
+	}
+
+	wd, err := unix.InotifyAddWatch(fd, fileName, watchFileMask)
+	if err != nil {
+		return false
+	}
+	w.fileWd = wd
+
+	if wd, err := unix.InotifyAddWatch(fd, filepath.Dir(fileName), watchDirMask); err == nil {
+		w.dirWd = wd
+	}
+	w.baseName = filepath.Base(fileName)
+
+	w.notify()
+	return true
+}
+
+// readEvents forwards relevant inotify events until the watcher closes.
+func (w *fileWatcher) readEvents() {
+	buf := make([]byte, 64*(unix.SizeofInotifyEvent+unix.NAME_MAX+1))
+
+	for {
+		n, err := w.file.Read(buf)
+		if err != nil {
+			return
+		}
+
+		for offset := 0; offset+unix.SizeofInotifyEvent <= n; {
+			event := (*unix.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			nameStart := offset + unix.SizeofInotifyEvent
+			nameEnd := min(nameStart+int(event.Len), n)
+			offset = nameEnd
+
+			// directory events matter only for the watched name
+			name, _, _ := strings.Cut(string(buf[nameStart:nameEnd]), "\x00")
+
+			w.mutex.Lock()
+			relevant := event.Mask&unix.IN_Q_OVERFLOW != 0 ||
+				int(event.Wd) != w.dirWd || name == w.baseName
+			w.mutex.Unlock()
+
+			if relevant {
+				w.notify()
+			}
+		}
+	}
+}
+
+// notify records a pending event without blocking.
+func (w *fileWatcher) notify() {
+	select {
+	case w.events <- struct{}{}:
+	default:
+	}
+}
+
+// wait blocks until the file changes, the reader is woken, or the
+// poll interval passes.
+func (w *fileWatcher) wait(wake <-chan struct{}) {
+	timeout := WATCH_POLL
+	if w.file != nil {
+		timeout = WATCH_BACKSTOP
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-w.events:
+	case <-wake:
+	case <-timer.C:
+	}
+}
+
+// close releases the inotify descriptor.
+func (w *fileWatcher) close() {
+	if w.file != nil {
+		w.file.Close()
+	}
+}
+
+// vim: set ts=4 sw=4 noet:
@@ -0,0 +1,140 @@
+// width.go
+// measure text in screen columns
+//
+// Copyright (c) 2024-2026 jjb
+// All rights reserved.
+//
+// This source code is licensed under the MIT license found
+// in the root directory of this source tree.
+
+package main
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mattn/go-runewidth"
+)
+
+// runeColumns returns the screen columns a rune takes: two for wide CJK
+// and emoji, none for combining marks. ASCII, including the control bytes
+// raw mode passes through, counts one.
+func runeColumns(r rune) int {
+	if r < utf8.RuneSelf {
+		return 1
+	}
+
+	return runewidth.RuneWidth(r)
+}
+
+// nextRune decodes the rune at the start of text and returns its size and
+// columns.
+func nextRune(text []byte) (int, int) {
+	if text[0] < utf8.RuneSelf {
+		return 1, 1
+	}
+
+	r, size := utf8.DecodeRune(text)
+	return size, runeColumns(r)
+}
+
+// textColumns returns the screen width of text.
+func textColumns(text []byte) int {
+	cols := 0
+	for i := 0; i < len(text); {
+		size, w := nextRune(text[i:])
+		cols += w
+		i += size
+	}
+
+	return cols
+}
+
+// columnIndex returns the byte offset of the first character at or past
+// column col, and how many columns past col it starts. A wide character
+// that straddles col is skipped, as are combining marks left without
+// their base.
+func columnIndex(text []byte, col int) (int, int) {
+	cols := 0
+	i := 0
+
+	for i < len(text) && cols < col {
+		size, w := nextRune(text[i:])
+		cols += w
+		i += size
+	}
+
+	for i < len(text) {
+		size, w := nextRune(text[i:])
+		if w > 0 {
+			break
+		}
+		i += size
+	}
+
+	return i, cols - min(cols, col)
+}
+
+// columnCounter converts increasing byte offsets in a line to columns.
+type columnCounter struct {
+	text []byte
+	pos  int
+	col  int
+}
+
+// column returns the column at which the byte at offset is shown.
+func (c *columnCounter) column(offset int) int {
+	for c.pos < offset && c.pos < len(c.text) {
+		size, w := nextRune(c.text[c.pos:])
+		c.col += w
+		c.pos += size
+	}
+
+	return c.col
+}
+
+// clipColumns cuts display text to width columns. Escape sequences past the
+// cut are kept, so colors and links still end. It returns the text and the
+// columns it takes.
+func clipColumns(text string, width int) (string, int) {
+	line := []byte(text)
+
+	var sb strings.Builder
+	sb.Grow(len(line))
+
+	cols := 0
+	cut := false
+
+	for i := 0; i < len(line); {
+		if line[i] == '\033' {
+			n, _ := escapeSequence(line[i:])
+			sb.Write(line[i : i+n])
+			i += n
+			continue
+		}
+
+		if isControl(line[i]) {
+			// raw controls take no columns, and go no further than the text
+			if !cut {
+				sb.WriteByte(line[i])
+			}
+			i++
+			continue
+		}
+
+		size, w := nextRune(line[i:])
+		if cols+w > width {
+			cut = true
+		}
+
+		if !cut {
+			sb.Write(line[i : i+size])
+			cols += w
+		}
+		i += size
+	}
+
+	return sb.String(), cols
+}
+
+// vim: set ts=4 sw=4 noet:
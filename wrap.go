@@ -0,0 +1,309 @@
+// wrap.go
+// soft-wrap display of long lines
+//
+// Copyright (c) 2024-2026 jjb
+// All rights reserved.
+//
+// This source code is licensed under the MIT license found
+// in the root directory of this source tree.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// WRAP_CACHE_MAX bounds the wrapped lines whose row counts are kept.
+const WRAP_CACHE_MAX = 4096
+
+// wrapCache keeps the row counts of wrapped lines for one width and one
+// line map, so paging does not read each line again.
+type wrapCache struct {
+	width   int
+	fileSeq uint64
+	mapSeq  uint64
+	rows    map[int]int
+}
+
+// In wrap mode a page position is a line and a wrapped row (segment)
+// within it. firstRow/firstSeg is the top of the page and
+// lastRow/lastSeg the first position below it.
+
+// wrapping reports whether long lines are wrapped; hex rows, directory
+// listings and columns never are.
+func (br *browseObj) wrapping() bool {
+	return br.modeWrap && !br.modeHex && br.dirList == nil && !br.modeTable
+}
+
+// wrapWidth returns the text columns available on each screen row.
+func (br *browseObj) wrapWidth() int {
+	// the last column is cleared by CLEARLINE
+	width := br.dispWidth - 1
+	if br.modeNumbers {
+		width -= NUMCOLWIDTH
+	}
+
+	return max(width, 1)
+}
+
+// wrapBounds splits a line into byte ranges that fit width columns. A wide
+// character that does not fit moves to the next row, and combining marks
+// stay with the character before them.
+func wrapBounds(line []byte, width int) [][2]int {
+	var bounds [][2]int
+
+	start, cols := 0, 0
+
+	for i := 0; i < len(line); {
+		size, w := nextRune(line[i:])
+
+		if cols > 0 && cols+w > width {
+			bounds = append(bounds, [2]int{start, i})
+			start, cols = i, 0
+		}
+
+		cols += w
+		i += size
+	}
+
+	return append(bounds, [2]int{start, len(line)})
+}
+
+// wrapCount returns the number of screen rows a line occupies.
+func (br *browseObj) wrapCount(lineno, mapSize int) int {
+	if lineno <= 0 || lineno >= mapSize {
+		// SOF and EOF markers
+		return 1
+	}
+
+	br.mutex.Lock()
+	fileSeq, mapSeq := br.fileSeq, br.mapSeq
+	br.mutex.Unlock()
+
+	width := br.wrapWidth()
+	c := &br.wraps
+
+	if c.rows == nil || len(c.rows) >= WRAP_CACHE_MAX || c.width != width || c.fileSeq != fileSeq || c.mapSeq != mapSeq {
+		*c = wrapCache{width: width, fileSeq: fileSeq, mapSeq: mapSeq, rows: make(map[int]int)}
+	}
+
+	if rows, ok := c.rows[lineno]; ok {
+		return rows
+	}
+
+	rows := len(wrapBounds(br.readFromMap(lineno), width))
+
+	// the last line may still be growing
+	if lineno < mapSize-1 {
+		c.rows[lineno] = rows
+	}
+
+	return rows
+}
+
+// wrapRows renders the screen rows of a line with search highlighting.
+func (br *browseObj) wrapRows(lineno int) []string {
+	input, spans := br.readVisible(lineno)
+	bounds := wrapBounds(input, br.wrapWidth())
+	spans = br.lineColors(input, spans)
+
+	paint := br.matchPaint(lineno, input)
+
+	rows := make([]string, len(bounds))
+	for i, b := range bounds {
+		text := br.paintSegment(input, b[0], b[1], paint, "", spans)
+
+		if i == 0 {
+			rows[i] = br.formatLine(lineno, text)
+		} else {
+			rows[i] = br.formatContinuation(text)
+		}
+	}
+
+	return rows
+}
+
+// formatContinuation formats a wrapped row after the first.
+func (br *browseObj) formatContinuation(content string) string {
+	content = linkURLs(content)
+
+	if br.modeNumbers {
+		return strings.Repeat(" ", NUMCOLWIDTH) + content
+	}
+
+	return content
+}
+
+// wrapForward moves a position down by count screen rows.
+func (br *browseObj) wrapForward(line, seg, count, mapSize int) (int, int) {
+	for count > 0 && line < mapSize {
+		rows := br.wrapCount(line, mapSize)
+
+		if seg+count < rows {
+			return line, seg + count
+		}
+
+		count -= rows - seg
+		line, seg = line+1, 0
+	}
+
+	return line, seg
+}
+
+// wrapBack moves a position up by count screen rows.
+func (br *browseObj) wrapBack(line, seg, count, mapSize int) (int, int) {
+	for count > 0 {
+		if seg >= count {
+			return line, seg - count
+		}
+
+		if line <= 0 {
+			return 0, 0
+		}
+
+		count -= seg + 1
+		line--
+		seg = br.wrapCount(line, mapSize) - 1
+	}
+
+	return line, seg
+}
+
+// wrapMaxTop returns the lowest top position that still fills the page.
+func (br *browseObj) wrapMaxTop(mapSize int) (int, int) {
+	return br.wrapBack(mapSize, 0, br.dispRows-1, mapSize)
+}
+
+// wrapClampTop limits a top position to the valid range.
+func (br *browseObj) wrapClampTop(line, seg, mapSize int) (int, int) {
+	if line < 0 {
+		return 0, 0
+	}
+
+	maxLine, maxSeg := br.wrapMaxTop(mapSize)
+	if line > maxLine || (line == maxLine && seg > maxSeg) {
+		return maxLine, maxSeg
+	}
+
+	return line, min(seg, br.wrapCount(line, mapSize)-1)
+}
+
+// printWrapPage renders a page in wrap mode starting at a position.
+func (br *browseObj) printWrapPage(line, seg int) {
+	if br.repaints() {
+		br.paintPane(line, seg)
+		return
+	}
+
+	mapSize := br.currentMapSize()
+	line, seg = br.wrapClampTop(line, seg, mapSize)
+	br.setEOFState(false, false)
+
+	// Only one cursor move here for all rows
+	// rows start with \n
+	moveCursor(1, 1, false)
+
+	rows := 0
+	i, s := line, seg
+
+	for rows < br.dispRows && i <= mapSize {
+		if i == 0 || i == mapSize {
+			// SOF and EOF markers
+			br.printLineWithMapSize(i, mapSize)
+			rows++
+			i, s = i+1, 0
+			continue
+		}
+
+		texts := br.wrapRows(i)
+		for ; s < len(texts) && rows < br.dispRows; s++ {
+			os.Stdout.WriteString("\n" + texts[s] + VIDOFF + CLEARLINE)
+			rows++
+		}
+
+		if s < len(texts) {
+			// partial line at the bottom
+			break
+		}
+
+		i, s = i+1, 0
+	}
+
+	// reset
+	fmt.Print(SGR0)
+
+	br.firstRow, br.firstSeg = line, seg
+	br.lastRow, br.lastSeg = i, s
+	br.shownMsg = false
+	moveCursor(2, 1, false)
+}
+
+// wrapScrollDown moves the wrapped page toward EOF.
+func (br *browseObj) wrapScrollDown(count int) {
+	if br.hitEOFState() && !br.shownMsg {
+		// nothing more to show
+		br.restoreCursor()
+		return
+	}
+
+	line, seg := br.wrapForward(br.firstRow, br.firstSeg, count, br.currentMapSize())
+	br.printWrapPage(line, seg)
+	br.restoreCursor()
+}
+
+// wrapScrollUp moves the wrapped page toward SOF.
+func (br *browseObj) wrapScrollUp(count int) {
+	if br.firstRow <= 0 && br.firstSeg <= 0 {
+		br.modeScroll = MODE_SCROLL_NONE
+		br.restoreLast()
+		return
+	}
+
+	line, seg := br.wrapBack(br.firstRow, br.firstSeg, count, br.currentMapSize())
+	br.printWrapPage(line, seg)
+	br.restoreCursor()
+}
+
+// restoreCursor parks the cursor for the current scroll mode.
+func (br *browseObj) restoreCursor() {
+	if br.inMotion() {
+		fmt.Print(CURRESTORE)
+	} else {
+		moveCursor(2, 1, false)
+	}
+}
+
+// wrapDisplayTop returns the top line that shows lineno below rows of
+// context, counting wrapped rows.
+func (br *browseObj) wrapDisplayTop(lineno, rows int) int {
+	mapSize := br.currentMapSize()
+	top := lineno
+
+	for top > 0 {
+		rows -= br.wrapCount(top-1, mapSize)
+		if rows < 0 {
+			break
+		}
+		top--
+	}
+
+	return top
+}
+
+// toggleWrap switches between wrapped and clipped long lines.
+func (br *browseObj) toggleWrap() {
+	br.modeWrap = !br.modeWrap
+	br.shiftWidth = 0
+	br.firstSeg, br.lastSeg = 0, 0
+	br.pageCurrent()
+
+	if br.modeWrap {
+		br.printMessage("Wrap long lines", MSG_GREEN)
+	} else {
+		br.printMessage("Clip long lines", MSG_GREEN)
+	}
+}
+
+// vim: set ts=4 sw=4 noet: